@@ -7,3 +7,5 @@ require gocv.io/x/gocv v0.34.0
 require github.com/gorilla/mux v1.8.0
 
 require github.com/gorilla/websocket v1.5.0
+
+require gopkg.in/yaml.v3 v3.0.1
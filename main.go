@@ -7,6 +7,7 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"sync"
 
 	"SekaiSubtitle-Core/process"
@@ -362,16 +363,101 @@ func test() {
 	// 	}
 	// }
 }
+func exportTheme(file string) {
+	t, _ := json.MarshalIndent(process.DefaultStyleTheme(), "", "  ")
+	process.WriteFileString(file, string(t))
+	log.Printf("Default Style Theme Exported to %s\n", file)
+}
+func exportSheet(storyFile, file string) {
+	data := process.ReadStoryData(strings.Split(storyFile, ","))
+	err := process.ExportSheet(data, file)
+	if err != nil {
+		log.Fatalln("Error during sheet exporting:", err)
+	}
+	log.Printf("Story with %d Events Exported to %s\n", data.Data.Count(), file)
+}
+func importSheet(storyFile, file string) {
+	data := process.ReadStoryData(strings.Split(storyFile, ","))
+	result, report, err := process.ImportSheet(data, file)
+	if err != nil {
+		log.Fatalln("Error during sheet importing:", err)
+	}
+	for _, s := range report {
+		log.Println("Mismatch:", s)
+	}
+	output := strings.TrimSuffix(file, ".csv") + ".pjs.txt"
+	process.WriteFileString(output, result.String())
+	log.Printf("Sheet Imported to %s with %d Mismatches\n", output, len(report))
+	voiced := 0
+	for _, event := range result.Data {
+		if event.VoiceId != "" {
+			voiced += 1
+		}
+	}
+	if voiced > 0 {
+		log.Printf("%d Lines Written with VoiceId as 7th Field\n", voiced)
+	}
+}
+func lint(storyFile, size string) {
+	var w, h int
+	if _, err := fmt.Sscanf(size, "%dx%d", &w, &h); err != nil || w <= 0 || h <= 0 {
+		log.Fatalln("Error during lint size parsing:", size)
+	}
+	errors := 0
+	findings := process.Lint(process.ReadStoryData(strings.Split(storyFile, ",")), h, w, nil)
+	for _, f := range findings {
+		if f.Severity == process.LintError {
+			errors += 1
+		}
+		fmt.Println(f.String())
+	}
+	log.Printf("Lint Found %d Errors, %d Warnings\n", errors, len(findings)-errors)
+	if errors > 0 {
+		os.Exit(1)
+	}
+}
 func main() {
 	var printVersion bool
 	var testRun bool
 	var port int
+	var themeFile string
+	var characterFile string
+	var storyFile string
+	var sheetExport string
+	var sheetImport string
+	var lintRun bool
+	var lintSize string
 	flag.BoolVar(&printVersion, "v", false, "Print Core Version")
 	flag.BoolVar(&testRun, "t", false, "run test()")
 	flag.IntVar(&port, "p", 50000, "Select Core Port")
+	flag.StringVar(&themeFile, "export-theme", "", "Export Default Style Theme to File")
+	flag.StringVar(&characterFile, "characters", "", "Load Character Database File")
+	flag.StringVar(&storyFile, "story", "", "Story Data Files for Sheets, Comma Separated")
+	flag.StringVar(&sheetExport, "export-sheet", "", "Export Story to Translation Sheet File")
+	flag.StringVar(&sheetImport, "import-sheet", "", "Import Translation Sheet File into PJS Story File")
+	flag.BoolVar(&lintRun, "lint", false, "Lint Story Files Given by -story")
+	flag.StringVar(&lintSize, "lint-size", "1920x1080", "Video Size Used by -lint")
 	flag.Parse()
+	if characterFile != "" {
+		n, err := process.LoadCharacterDatabase(characterFile)
+		if err != nil {
+			log.Fatalln("Error during character database loading:", err)
+		}
+		log.Printf("Loaded %d Character Entries from %s\n", n, characterFile)
+		for _, style := range process.Characters.UnknownStyles() {
+			log.Printf("Warning: Character Style %s Is Not a Dialog Style\n", style)
+		}
+	}
 	if printVersion {
 		fmt.Println(AppVersion)
+	} else if themeFile != "" {
+		exportTheme(themeFile)
+	} else if sheetExport != "" {
+		exportSheet(storyFile, sheetExport)
+	} else if sheetImport != "" {
+		importSheet(storyFile, sheetImport)
+	} else if lintRun {
+		lint(storyFile, lintSize)
 	} else if testRun {
 		test()
 	} else {
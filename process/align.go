@@ -0,0 +1,351 @@
+package process
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// ALIGNMENT
+// Detected dialog runs are aligned to the story dialog lines with dynamic
+// programming instead of by index, so one spurious split or missed line does
+// not shift every following translation onto the wrong line.
+
+const (
+	alignMissCost        = 1.2
+	alignExtraCost       = 1.0
+	alignSplitCost       = 0.6
+	alignMergeCost       = 0.6
+	alignLowConfidence   = 0.5
+	alignSpeakerDistance = 0.08
+)
+
+type dialogAssignment struct {
+	Op         string
+	Runs       []int
+	Lines      []int
+	Confidence float64
+}
+
+type alignedDialog struct {
+	Frames     []dialogFrame
+	Line       int
+	Phase      dialogPhase
+	Confidence float64
+}
+
+type dialogAlignment struct {
+	runs           [][]dialogFrame
+	lines          StoryEventSet
+	phases         []dialogPhase
+	frameTimeMs    float64
+	charTimeMs     int
+	useBox         bool
+	useSpeaker     bool
+	runCloses      []bool
+	runPlateChange []bool
+	linePeriod     []bool
+	lineSpeaker    []bool
+	forced         map[int]int
+}
+
+func newDialogAlignment(storyData PJSTranslationData, matched matchResult, phases []dialogPhase, charTimeMs int) dialogAlignment {
+	a := dialogAlignment{
+		runs:        matched.dialogFrameSet,
+		lines:       storyData.Dialogs(),
+		phases:      phases,
+		frameTimeMs: matched.frameTimeMs,
+		charTimeMs:  charTimeMs,
+		useBox:      len(matched.dialogBoxSet) > 0,
+		useSpeaker:  len(matched.dialogSignatures) == len(matched.dialogFrameSet),
+		forced:      matched.forcedLines["Dialog"],
+	}
+	if a.charTimeMs <= 0 {
+		a.charTimeMs = 80
+	}
+	for i := range a.runs {
+		a.runCloses = append(a.runCloses, phases[i].closes())
+		plateChange := false
+		if a.useSpeaker && i > 0 {
+			plateChange = signatureDistance(matched.dialogSignatures[i-1], matched.dialogSignatures[i]) > alignSpeakerDistance
+		}
+		a.runPlateChange = append(a.runPlateChange, plateChange)
+	}
+	for j, line := range a.lines {
+		a.linePeriod = append(a.linePeriod, storyData.DialogClosesBox(j))
+		a.lineSpeaker = append(a.lineSpeaker, j > 0 && line.CharacterO != a.lines[j-1].CharacterO)
+	}
+	return a
+}
+
+func (a dialogAlignment) runFrames(r int) float64 {
+	frames := a.runs[r]
+	return float64(frames[len(frames)-1].FrameId - frames[0].FrameId + 1)
+}
+func (a dialogAlignment) expectedFrames(lines ...int) float64 {
+	var ms float64
+	for _, l := range lines {
+		ms += float64(utf8.RuneCountInString(a.lines[l].ContentO)*a.charTimeMs + 1000)
+	}
+	return ms / a.frameTimeMs
+}
+func (a dialogAlignment) durationCost(r int, lines ...int) float64 {
+	return 0.4 * math.Min(math.Abs(math.Log(a.runFrames(r)/a.expectedFrames(lines...))), 2)
+}
+
+// isForced reports whether run r has a line assigned by corrections.
+func (a dialogAlignment) isForced(r int) bool {
+	_, ok := a.forced[r]
+	return ok
+}
+func (a dialogAlignment) matchCost(r, l int) float64 {
+	if line, ok := a.forced[r]; ok {
+		if line == l {
+			return 0
+		}
+		return math.Inf(1)
+	}
+	cost := a.durationCost(r, l)
+	if a.useBox && a.runCloses[r] != a.linePeriod[l] {
+		cost += 0.4
+	}
+	if a.useSpeaker && r > 0 && l > 0 && a.runPlateChange[r] != a.lineSpeaker[l] {
+		cost += 0.3
+	}
+	return cost
+}
+func (a dialogAlignment) splitCost(r int) float64 {
+	if a.isForced(r) {
+		return math.Inf(1)
+	}
+	cost := alignSplitCost
+	if a.runPlateChange[r] {
+		cost += 0.5
+	}
+	if a.useBox && a.runCloses[r-1] {
+		cost += 0.5
+	}
+	return cost
+}
+func (a dialogAlignment) mergeCost(r, l int) float64 {
+	if a.isForced(r) {
+		return math.Inf(1)
+	}
+	cost := alignMergeCost + a.durationCost(r, l, l+1)
+	if a.useBox && a.linePeriod[l] {
+		cost += 0.5
+	}
+	if a.lineSpeaker[l+1] {
+		cost += 0.3
+	}
+	return cost
+}
+
+func (a dialogAlignment) align() []dialogAssignment {
+	m, n := len(a.runs), len(a.lines)
+	cost := make([][]float64, m+1)
+	step := make([][]string, m+1)
+	for i := range cost {
+		cost[i] = make([]float64, n+1)
+		step[i] = make([]string, n+1)
+		for j := range cost[i] {
+			cost[i][j] = math.Inf(1)
+		}
+	}
+	cost[0][0] = 0
+	relax := func(i, j int, c float64, op string) {
+		if c < cost[i][j] {
+			cost[i][j] = c
+			step[i][j] = op
+		}
+	}
+	for i := 0; i <= m; i++ {
+		for j := 0; j <= n; j++ {
+			if i >= 1 && j >= 1 {
+				relax(i, j, cost[i-1][j-1]+a.matchCost(i-1, j-1), "match")
+			}
+			if j >= 1 {
+				relax(i, j, cost[i][j-1]+alignMissCost, "missing")
+			}
+			if i >= 2 && j >= 1 {
+				relax(i, j, cost[i-1][j]+a.splitCost(i-1), "split")
+			}
+			if i >= 1 && j == 0 && !a.isForced(i-1) {
+				relax(i, j, cost[i-1][j]+alignExtraCost, "extra")
+			}
+			if i >= 1 && j >= 2 {
+				relax(i, j, cost[i-1][j-2]+a.mergeCost(i-1, j-2), "merge")
+			}
+		}
+	}
+
+	var ops []dialogAssignment
+	for i, j := m, n; i > 0 || j > 0; {
+		switch step[i][j] {
+		case "match":
+			ops = append(ops, dialogAssignment{Op: "match", Runs: []int{i - 1}, Lines: []int{j - 1},
+				Confidence: math.Exp(-a.matchCost(i-1, j-1))})
+			i, j = i-1, j-1
+		case "missing":
+			ops = append(ops, dialogAssignment{Op: "missing", Lines: []int{j - 1}})
+			j -= 1
+		case "split":
+			ops = append(ops, dialogAssignment{Op: "split", Runs: []int{i - 1}, Lines: []int{j - 1},
+				Confidence: math.Exp(-a.splitCost(i - 1))})
+			i -= 1
+		case "extra":
+			ops = append(ops, dialogAssignment{Op: "extra", Runs: []int{i - 1}})
+			i -= 1
+		case "merge":
+			ops = append(ops, dialogAssignment{Op: "merge", Runs: []int{i - 1}, Lines: []int{j - 2, j - 1},
+				Confidence: math.Exp(-a.mergeCost(i-1, j-2))})
+			i, j = i-1, j-2
+		}
+	}
+
+	// Walk forward and fold split runs into the assignment of their line. A split
+	// continuing the second line of a merge adds its run to that merge.
+	var result []dialogAssignment
+	for k := len(ops) - 1; k >= 0; k-- {
+		op := ops[k]
+		if op.Op == "split" && len(result) > 0 {
+			last := &result[len(result)-1]
+			if last.Op == "merge" && last.Lines[1] == op.Lines[0] {
+				last.Confidence = math.Min(last.Confidence, op.Confidence)
+				last.Runs = append(last.Runs, op.Runs...)
+				continue
+			}
+			if len(last.Lines) == 1 && last.Lines[0] == op.Lines[0] {
+				if last.Op == "missing" {
+					last.Op = "match"
+					last.Confidence = op.Confidence
+				} else {
+					last.Op = "split"
+					last.Confidence = math.Min(last.Confidence, op.Confidence)
+				}
+				last.Runs = append(last.Runs, op.Runs...)
+				continue
+			}
+		}
+		if op.Op == "split" {
+			op.Op = "match"
+		}
+		result = append(result, op)
+	}
+	return result
+}
+
+func (a dialogAlignment) apply(assignments []dialogAssignment) []alignedDialog {
+	var result []alignedDialog
+	for _, as := range assignments {
+		switch as.Op {
+		case "match", "extra":
+			line := -1
+			if len(as.Lines) > 0 {
+				line = as.Lines[0]
+			}
+			result = append(result, alignedDialog{
+				Frames: a.runs[as.Runs[0]], Line: line, Phase: a.phases[as.Runs[0]], Confidence: as.Confidence})
+		case "split":
+			var frames []dialogFrame
+			for _, r := range as.Runs {
+				frames = append(frames, a.runs[r]...)
+			}
+			first, last := a.phases[as.Runs[0]], a.phases[as.Runs[len(as.Runs)-1]]
+			result = append(result, alignedDialog{Frames: frames, Line: as.Lines[0], Confidence: as.Confidence,
+				Phase: dialogPhase{OpenStart: first.OpenStart, OpenEnd: first.OpenEnd,
+					CloseStart: last.CloseStart, CloseEnd: last.CloseEnd}})
+		case "merge":
+			// The first run holds both lines, runs folded in from splits continue the second.
+			frames := a.runs[as.Runs[0]]
+			phase := a.phases[as.Runs[0]]
+			var rest []dialogFrame
+			for _, r := range as.Runs[1:] {
+				rest = append(rest, a.runs[r]...)
+			}
+			last := a.phases[as.Runs[len(as.Runs)-1]]
+			if len(frames) < 2 {
+				result = append(result, alignedDialog{Frames: frames, Line: as.Lines[0], Phase: phase, Confidence: as.Confidence})
+				if len(rest) > 0 {
+					result = append(result, alignedDialog{Frames: rest, Line: as.Lines[1], Confidence: as.Confidence,
+						Phase: dialogPhase{CloseStart: last.CloseStart, CloseEnd: last.CloseEnd}})
+				}
+				continue
+			}
+			e0, e1 := a.expectedFrames(as.Lines[0]), a.expectedFrames(as.Lines[1])
+			cut := int(float64(len(frames)) * e0 / (e0 + e1))
+			cut = MaxInt([]int{1, MinInt([]int{len(frames) - 1, cut})})
+			second := append(append([]dialogFrame{}, frames[cut:]...), rest...)
+			result = append(result,
+				alignedDialog{Frames: frames[:cut], Line: as.Lines[0], Confidence: as.Confidence,
+					Phase: dialogPhase{OpenStart: phase.OpenStart, OpenEnd: phase.OpenEnd}},
+				alignedDialog{Frames: second, Line: as.Lines[1], Confidence: as.Confidence,
+					Phase: dialogPhase{CloseStart: last.CloseStart, CloseEnd: last.CloseEnd}})
+		}
+	}
+	return result
+}
+
+func joinIndexes(arr []int) string {
+	var s []string
+	for _, v := range arr {
+		s = append(s, fmt.Sprintf("%d", v+1))
+	}
+	return strings.Join(s, ",")
+}
+
+// alignDialogs assigns the detected dialog runs to story dialog lines and logs
+// which lines were merged, split or missing and which assignments are uncertain.
+func (t *Task) alignDialogs(storyData PJSTranslationData, matched matchResult, phases []dialogPhase) []alignedDialog {
+	if t.Config.VideoOnly || storyData.Dialogs().Count() == 0 {
+		var result []alignedDialog
+		for i, frames := range matched.dialogFrameSet {
+			result = append(result, alignedDialog{Frames: frames, Line: -1, Phase: phases[i], Confidence: 1})
+		}
+		return result
+	}
+	a := newDialogAlignment(storyData, matched, phases, t.Config.TyperInterval[1])
+	var lastForced = -1
+	for r := range a.runs {
+		line, ok := a.forced[r]
+		if !ok {
+			continue
+		}
+		if line <= lastForced || line >= len(a.lines) {
+			go t.Log(Log{Type: "string",
+				Data: fmt.Sprintf("[Warning] Ignored Correction Assigning Run %d to Line %d", r+1, line+1)})
+			delete(a.forced, r)
+			continue
+		}
+		lastForced = line
+	}
+	assignments := a.align()
+	var counts = map[string]int{}
+	for _, as := range assignments {
+		counts[as.Op] += 1
+		switch as.Op {
+		case "merge":
+			go t.Log(Log{Type: "string",
+				Data: fmt.Sprintf("[Alignment] Lines %s Merged in Run %s", joinIndexes(as.Lines), joinIndexes(as.Runs))})
+		case "split":
+			go t.Log(Log{Type: "string",
+				Data: fmt.Sprintf("[Alignment] Line %s Split Across Runs %s", joinIndexes(as.Lines), joinIndexes(as.Runs))})
+		case "missing":
+			go t.Log(Log{Type: "string",
+				Data: fmt.Sprintf("[Alignment] Line %s Missing in Video", joinIndexes(as.Lines))})
+		case "extra":
+			go t.Log(Log{Type: "string",
+				Data: fmt.Sprintf("[Alignment] Run %s Has No Story Line", joinIndexes(as.Runs))})
+		}
+		if as.Op != "missing" && as.Op != "extra" && as.Confidence < alignLowConfidence {
+			go t.Log(Log{Type: "string",
+				Data: fmt.Sprintf("[Warning] Low Confidence %.2f Assigning Run %s to Line %s",
+					as.Confidence, joinIndexes(as.Runs), joinIndexes(as.Lines))})
+		}
+	}
+	go t.Log(Log{Type: "string",
+		Data: fmt.Sprintf("[Alignment] Aligned %d Runs to %d Lines: %d Matched, %d Merged, %d Split, %d Missing, %d Extra",
+			len(a.runs), len(a.lines), counts["match"], counts["merge"], counts["split"], counts["missing"], counts["extra"])})
+	return a.apply(assignments)
+}
@@ -0,0 +1,78 @@
+package process
+
+// BILINGUAL
+// Bilingual output keeps one line per event: the primary text in the usual style
+// and the other language below it, reset to a smaller secondary style with {\r}.
+
+const (
+	BilingualTranslation = "translation"
+	BilingualOriginal    = "original"
+)
+
+const (
+	DialogSecondaryStyle  = "dialog_secondary"
+	AddressSecondaryStyle = "address_secondary"
+)
+
+const DefaultSecondaryScale = 0.7
+
+// bilingualContent is the primary content of an event and the secondary body shown
+// below it, which is empty when bilingual output is off or both languages are the same.
+func (c TaskConfig) bilingualContent(s StoryEvent) (primary EventContent, secondary string) {
+	primary = s.Content()
+	if c.Bilingual == BilingualOriginal {
+		primary = EventContent{Body: s.ContentO, Character: s.CharacterO}
+		secondary = s.ContentT
+	} else if c.Bilingual == BilingualTranslation {
+		secondary = s.ContentO
+	}
+	if secondary == primary.Body {
+		secondary = ""
+	}
+	if len(primary.Body) == 0 {
+		primary, secondary = s.Content(), ""
+	}
+	return
+}
+
+func (c TaskConfig) secondaryConfig() TaskConfig {
+	config := c
+	config.TyperMode = c.SecondaryTyperMode
+	if config.TyperMode == "" {
+		config.TyperMode = TyperModeNone
+	}
+	return config
+}
+
+func secondaryLine(body string, style string) string {
+	if len(body) == 0 {
+		return ""
+	}
+	return "\\N{\\r" + style + "}" + body
+}
+
+func (c TaskConfig) secondaryScale() float64 {
+	if c.SecondaryScale <= 0 {
+		return DefaultSecondaryScale
+	}
+	return c.SecondaryScale
+}
+
+func makeSecondaryStyles(config TaskConfig, styles []SubtitleStyleItem) []SubtitleStyleItem {
+	var scale = config.secondaryScale()
+	var result []SubtitleStyleItem
+	for _, style := range styles {
+		var s = style
+		switch style.Name {
+		case "関連人物":
+			s.Name = DialogSecondaryStyle
+		case "address":
+			s.Name = AddressSecondaryStyle
+		default:
+			continue
+		}
+		s.Fontsize = int(float64(style.Fontsize) * scale)
+		result = append(result, s)
+	}
+	return result
+}
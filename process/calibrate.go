@@ -0,0 +1,122 @@
+package process
+
+import (
+	"fmt"
+	"image"
+	"sort"
+
+	"gocv.io/x/gocv"
+)
+
+type MatchThresholds struct {
+	Pointer float64 `json:"pointer"`
+	Marker  float64 `json:"marker"`
+	Banner  float64 `json:"banner"`
+	Menu    float64 `json:"menu"`
+}
+
+var DefaultMatchThresholds = MatchThresholds{Pointer: 0.8, Marker: 0.8, Banner: 0.4, Menu: 0.7}
+
+func (m MatchThresholds) String() string {
+	return fmt.Sprintf("Pointer %.3f, Marker %.3f, Banner %.3f, Menu %.3f", m.Pointer, m.Marker, m.Banner, m.Menu)
+}
+
+const calibrateSampleCount = 120
+
+// splitBimodal picks the threshold separating the low (absent) and high (present)
+// clusters of a score distribution by maximizing the between-class variance.
+// Unimodal distributions keep the fallback value.
+func splitBimodal(scores []float64, fallback, low, high float64) float64 {
+	n := len(scores)
+	if n < 4 {
+		return fallback
+	}
+	sorted := append([]float64{}, scores...)
+	sort.Float64s(sorted)
+
+	var total float64
+	for _, v := range sorted {
+		total += v
+	}
+	var bestVariance, bestMeanGap float64
+	bestIndex := -1
+	var sum float64
+	for k := 1; k < n; k++ {
+		sum += sorted[k-1]
+		if sorted[k] == sorted[k-1] {
+			continue
+		}
+		w0 := float64(k) / float64(n)
+		w1 := 1 - w0
+		m0 := sum / float64(k)
+		m1 := (total - sum) / float64(n-k)
+		variance := w0 * w1 * (m1 - m0) * (m1 - m0)
+		if variance > bestVariance {
+			bestVariance = variance
+			bestIndex = k
+			bestMeanGap = m1 - m0
+		}
+	}
+	if bestIndex < 2 || n-bestIndex < 2 || bestMeanGap < 0.25 {
+		return fallback
+	}
+	threshold := (sorted[bestIndex-1] + sorted[bestIndex]) / 2
+	if threshold < low {
+		threshold = low
+	}
+	if threshold > high {
+		threshold = high
+	}
+	return threshold
+}
+
+func (t *Task) calibrate(vc *gocv.VideoCapture, h, w, startFrame, endFrame int) MatchThresholds {
+	var templateDialogPointer = getResizedDialogPointer(h, w)
+	var templateMenuSign = getResizedInterfaceMenu(h, w)
+	var templateMarker = getResizedAreaMarkerTemplate(h, w)
+	var templateBannerCanny, templateBannerReverse, bannerArea = getBannerEdgeTemplates(h, w)
+	var pointerScores, markerScores, bannerScores, menuScores []float64
+	step := (endFrame - startFrame) / calibrateSampleCount
+	if step < 1 {
+		step = 1
+	}
+	for frameId := startFrame; frameId < endFrame; frameId += step {
+		if t.Stopped {
+			break
+		}
+		vc.Set(gocv.VideoCapturePosFrames, float64(frameId))
+		var frame = gocv.NewMat()
+		vc.Read(&frame)
+		if frame.Empty() {
+			_ = frame.Close()
+			break
+		}
+		gocv.CvtColor(frame, &frame, gocv.ColorBGRToGray)
+
+		pointerScore, _ := scoreFrameDialogPointer(frame, templateDialogPointer, image.Point{})
+		markerScore, _ := scoreFrameAreaMarker(frame, templateMarker)
+		pointerScores = append(pointerScores, float64(pointerScore))
+		markerScores = append(markerScores, float64(markerScore))
+		bannerScores = append(bannerScores,
+			float64(scoreFrameAreaBannerEdge(frame, templateBannerCanny, templateBannerReverse, bannerArea)))
+		menuScores = append(menuScores, float64(scoreFrameContentStart(frame, templateMenuSign)))
+		_ = frame.Close()
+	}
+	vc.Set(gocv.VideoCapturePosFrames, float64(startFrame))
+	_ = templateDialogPointer.Close()
+	_ = templateMenuSign.Close()
+	_ = templateMarker.Close()
+	_ = templateBannerCanny.Close()
+	_ = templateBannerReverse.Close()
+
+	d := DefaultMatchThresholds
+	result := MatchThresholds{
+		Pointer: splitBimodal(pointerScores, d.Pointer, 0.6, 0.9),
+		Marker:  splitBimodal(markerScores, d.Marker, 0.6, 0.9),
+		Banner:  splitBimodal(bannerScores, d.Banner, 0.25, 0.6),
+		Menu:    splitBimodal(menuScores, d.Menu, 0.5, 0.85),
+	}
+	go t.Log(Log{Type: "string",
+		Data: fmt.Sprintf("[Initial] Calibrated Thresholds From %d Sample Frames: %s", len(pointerScores), result)})
+	return result
+}
@@ -0,0 +1,196 @@
+package process
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+)
+
+// CHARACTERS
+// The character database maps the Live2D character ids found in story assets
+// to characters, units, default styles and display names. The embedded maps are
+// the defaults and a database file loaded at startup adds or replaces entries,
+// so new event characters and unit variants don't need a core release.
+
+type CharacterEntry struct {
+	Character2DId int               `json:"character_2d_id"`
+	CharacterId   int               `json:"character_id"`
+	Unit          string            `json:"unit"`
+	Style         string            `json:"style"`
+	Names         map[string]string `json:"names"`
+}
+
+type CharacterDatabase struct {
+	Entries []CharacterEntry `json:"characters"`
+	byL2D   map[int]CharacterEntry
+	byCid   map[int]CharacterEntry
+}
+
+var Characters = DefaultCharacterDatabase()
+
+func DefaultCharacterDatabase() CharacterDatabase {
+	var db CharacterDatabase
+	var l2dIds []int
+	for l2d := range C2Did_to_Cid {
+		l2dIds = append(l2dIds, l2d)
+	}
+	sort.Ints(l2dIds)
+	for _, l2d := range l2dIds {
+		cid := C2Did_to_Cid[l2d]
+		entry := CharacterEntry{Character2DId: l2d, CharacterId: cid, Unit: CIDUnit[cid], Style: CIDStyle[cid]}
+		if entry.Style != "" {
+			entry.Names = map[string]string{"jp": entry.Style}
+		}
+		db.Entries = append(db.Entries, entry)
+	}
+	db.index()
+	return db
+}
+
+func (db *CharacterDatabase) index() {
+	db.byL2D = map[int]CharacterEntry{}
+	db.byCid = map[int]CharacterEntry{}
+	for _, entry := range db.Entries {
+		if entry.Character2DId != 0 {
+			db.byL2D[entry.Character2DId] = entry
+		}
+		if old, ok := db.byCid[entry.CharacterId]; entry.Character2DId != 0 && (!ok || entry.Character2DId < old.Character2DId) {
+			db.byCid[entry.CharacterId] = entry
+		}
+	}
+}
+
+// Merge adds the entries of another database, replacing entries with the same Live2D id.
+func (db CharacterDatabase) Merge(other CharacterDatabase) CharacterDatabase {
+	var result CharacterDatabase
+	var replaced = map[int]bool{}
+	for _, entry := range other.Entries {
+		if entry.Character2DId != 0 {
+			replaced[entry.Character2DId] = true
+		}
+	}
+	for _, entry := range db.Entries {
+		if !replaced[entry.Character2DId] {
+			result.Entries = append(result.Entries, entry)
+		}
+	}
+	result.Entries = append(append([]CharacterEntry{}, other.Entries...), result.Entries...)
+	result.index()
+	return result
+}
+
+func ReadCharacterDatabase(file string) (db CharacterDatabase, err error) {
+	dat, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(dat, &db)
+	db.index()
+	return
+}
+
+// LoadCharacterDatabase merges a database file into the embedded defaults.
+func LoadCharacterDatabase(file string) (int, error) {
+	db, err := ReadCharacterDatabase(file)
+	if err != nil {
+		return 0, err
+	}
+	Characters = DefaultCharacterDatabase().Merge(db)
+	return len(db.Entries), nil
+}
+
+// CharacterId is the character of a Live2D id, or 0 when the id is unknown or has no style.
+func (db CharacterDatabase) CharacterId(character2DId int) int {
+	entry, ok := db.byL2D[character2DId]
+	if !ok || db.Style(character2DId) == "" {
+		return 0
+	}
+	return entry.CharacterId
+}
+
+// Character2DId is the Live2D id a character is looked up with when only its
+// character id is known, the lowest one of the character.
+func (db CharacterDatabase) Character2DId(characterId int) int {
+	return db.byCid[characterId].Character2DId
+}
+
+// Style and Unit are looked up by Live2D id, since the unit variants of a
+// character share its character id but not its unit or style.
+func (db CharacterDatabase) Style(character2DId int) string {
+	entry, ok := db.byL2D[character2DId]
+	if !ok {
+		return ""
+	}
+	if entry.Style != "" {
+		return entry.Style
+	}
+	return CIDStyle[entry.CharacterId]
+}
+func (db CharacterDatabase) Unit(character2DId int) string {
+	entry, ok := db.byL2D[character2DId]
+	if !ok {
+		return ""
+	}
+	if entry.Unit != "" {
+		return entry.Unit
+	}
+	return CIDUnit[entry.CharacterId]
+}
+
+// Name is the display name of a Live2D id in a language ("jp", "cn", "en"),
+// falling back to the Japanese name.
+func (db CharacterDatabase) Name(character2DId int, lang string) string {
+	entry := db.byL2D[character2DId]
+	if name := entry.Names[lang]; name != "" {
+		return name
+	}
+	if name := entry.Names["jp"]; name != "" {
+		return name
+	}
+	return db.Style(character2DId)
+}
+
+// UnknownStyles lists the styles of the entries that are not among the generated dialog styles.
+func (db CharacterDatabase) UnknownStyles() []string {
+	var known = map[string]bool{}
+	for _, style := range GetDialogStyle() {
+		known[style.Name] = true
+	}
+	var unknown = map[string]bool{}
+	for _, entry := range db.Entries {
+		if entry.Style != "" && !known[entry.Style] {
+			unknown[entry.Style] = true
+		}
+	}
+	var result []string
+	for style := range unknown {
+		result = append(result, style)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// StyleUnit is the unit of the characters using a style. Several Live2D ids can
+// share a style, so the one with the lowest id wins.
+func (db CharacterDatabase) StyleUnit(style string) string {
+	var result string
+	var best = -1
+	for _, entry := range db.Entries {
+		unit := db.Unit(entry.Character2DId)
+		if db.Style(entry.Character2DId) != style || unit == "" {
+			continue
+		}
+		if best < 0 || entry.Character2DId < best {
+			result, best = unit, entry.Character2DId
+		}
+	}
+	if result != "" {
+		return result
+	}
+	for cid, s := range CIDStyle {
+		if s == style {
+			return CIDUnit[cid]
+		}
+	}
+	return ""
+}
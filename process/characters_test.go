@@ -0,0 +1,79 @@
+package process
+
+import "testing"
+
+func TestCharacterDatabaseMerge(t *testing.T) {
+	db := DefaultCharacterDatabase().Merge(CharacterDatabase{Entries: []CharacterEntry{
+		{Character2DId: 271, CharacterId: 116, Unit: "light_sound", Style: "初音ミク",
+			Names: map[string]string{"jp": "初音ミク", "cn": "初音未来", "en": "Hatsune Miku"}},
+		{Character2DId: 272, CharacterId: 21, Unit: "idol", Style: "初音ミク"},
+		{Character2DId: 900, CharacterId: 5, Style: "花里みのり"},
+	}})
+	tests := []struct {
+		l2d, cid    int
+		style, unit string
+		cn, en      string
+	}{
+		{1, 1, "星乃一歌", "light_sound", "星乃一歌", "星乃一歌"},
+		{21, 21, "初音ミク", "piapro", "初音ミク", "初音ミク"},
+		{271, 116, "初音ミク", "light_sound", "初音未来", "Hatsune Miku"},
+		{272, 21, "初音ミク", "idol", "初音ミク", "初音ミク"},
+		{273, 0, "", "", "", ""},
+		{900, 5, "花里みのり", "idol", "花里みのり", "花里みのり"},
+		{999, 0, "", "", "", ""},
+	}
+	for _, tt := range tests {
+		if got := db.CharacterId(tt.l2d); got != tt.cid {
+			t.Errorf("CharacterId(%d) = %d, want %d", tt.l2d, got, tt.cid)
+		}
+		if got := db.Style(tt.l2d); got != tt.style {
+			t.Errorf("Style(%d) = %q, want %q", tt.l2d, got, tt.style)
+		}
+		if got := db.Unit(tt.l2d); got != tt.unit {
+			t.Errorf("Unit(%d) = %q, want %q", tt.l2d, got, tt.unit)
+		}
+		if got := db.Name(tt.l2d, "cn"); got != tt.cn {
+			t.Errorf("Name(%d, cn) = %q, want %q", tt.l2d, got, tt.cn)
+		}
+		if got := db.Name(tt.l2d, "en"); got != tt.en {
+			t.Errorf("Name(%d, en) = %q, want %q", tt.l2d, got, tt.en)
+		}
+	}
+	if got := db.StyleUnit("初音ミク"); got != "piapro" {
+		t.Errorf("StyleUnit(初音ミク) = %q, want %q", got, "piapro")
+	}
+	if got := db.Character2DId(21); got != 21 {
+		t.Errorf("Character2DId(21) = %d, want 21", got)
+	}
+	if got := db.Character2DId(5); got != 5 {
+		t.Errorf("Character2DId(5) = %d, want 5", got)
+	}
+}
+
+func TestGlossaryCharacterName(t *testing.T) {
+	saved := Characters
+	defer func() { Characters = saved }()
+	Characters = DefaultCharacterDatabase().Merge(CharacterDatabase{Entries: []CharacterEntry{
+		{Character2DId: 21, CharacterId: 21, Style: "初音ミク", Names: map[string]string{"jp": "初音ミク", "cn": "初音未来"}},
+	}})
+	g := Glossary{Language: "cn", Characters: []GlossaryCharacter{{CharacterId: 1, Name: "一歌"}}}
+	usual := map[int]string{1: "一歌", 21: "ミク"}
+	tests := []struct {
+		event StoryEvent
+		name  string
+		ok    bool
+	}{
+		{StoryEvent{CharacterId: 1, CharacterO: "一歌"}, "一歌", true},
+		{StoryEvent{CharacterId: 1, CharacterO: "星乃一歌"}, "一歌", true},
+		{StoryEvent{CharacterId: 1, CharacterO: "？？？"}, "", false},
+		{StoryEvent{CharacterId: 21, CharacterO: "ミク", Character2DId: 21}, "初音未来", true},
+		{StoryEvent{CharacterId: 21, CharacterO: "初音ミク"}, "初音未来", true},
+		{StoryEvent{CharacterId: 0, CharacterO: "ミク"}, "", false},
+	}
+	for _, tt := range tests {
+		name, ok := g.characterName(tt.event, usual)
+		if name != tt.name || ok != tt.ok {
+			t.Errorf("characterName(%d %q) = %q, %v, want %q, %v", tt.event.CharacterId, tt.event.CharacterO, name, ok, tt.name, tt.ok)
+		}
+	}
+}
@@ -100,6 +100,14 @@ var CIDStyle = map[int]string{
 	25: "MEIKO",
 	26: "KAITO",
 }
+var CIDUnit = map[int]string{
+	1: "light_sound", 2: "light_sound", 3: "light_sound", 4: "light_sound",
+	5: "idol", 6: "idol", 7: "idol", 8: "idol",
+	9: "street", 10: "street", 11: "street", 12: "street",
+	13: "theme_park", 14: "theme_park", 15: "theme_park", 16: "theme_park",
+	17: "school_refusal", 18: "school_refusal", 19: "school_refusal", 20: "school_refusal",
+	21: "piapro", 22: "piapro", 23: "piapro", 24: "piapro", 25: "piapro", 26: "piapro",
+}
 var C2Did_to_Cid = map[int]int{
 	1:      1,
 	2:      2,
@@ -372,6 +380,28 @@ var C2Did_to_Cid = map[int]int{
 	276:    122,
 	900000: 900000,
 }
+var StaffRoleLabels = map[string]map[string]string{
+	"zh": {
+		"recorder": "录制", "translator": "翻译", "translate_proof": "校对", "subtitle_maker": "时轴",
+		"subtitle_proof": "轴校", "compositor": "压制", "typesetter": "特效", "qc": "质检", "audio": "音频",
+	},
+	"ja": {
+		"recorder": "録画", "translator": "翻訳", "translate_proof": "翻訳校正", "subtitle_maker": "タイミング",
+		"subtitle_proof": "タイミング校正", "compositor": "エンコード", "typesetter": "タイプセット", "qc": "QC", "audio": "音声",
+	},
+	"en": {
+		"recorder": "Recording", "translator": "Translation", "translate_proof": "Translation Check",
+		"subtitle_maker": "Timing", "subtitle_proof": "Timing Check", "compositor": "Encoding",
+		"typesetter": "Typesetting", "qc": "QC", "audio": "Audio",
+	},
+}
+
+// StaffSeparators holds the label/name, merged role and name list separators of each language.
+var StaffSeparators = map[string][3]string{
+	"zh": {"：", "&", "、"},
+	"ja": {"：", "&", "、"},
+	"en": {": ", " & ", ", "},
+}
 var StaffStyleFormat = SubtitleStyleItem{
 	Name:            "Staff",
 	FontName:        "思源黑体 CN Bold",
@@ -397,6 +427,56 @@ var StaffStyleFormat = SubtitleStyleItem{
 	MarginV:         30,
 	Encoding:        1,
 }
+var ChoiceStyleFormat = SubtitleStyleItem{
+	Name:            "choice",
+	FontName:        "思源黑体 CN Bold",
+	Fontsize:        70,
+	PrimaryColour:   "&H00574444",
+	SecondaryColour: "&H000000FF",
+	OutlineColour:   "&H00FFFFFF",
+	BackColour:      "&H00000000",
+	Bold:            0,
+	Italic:          0,
+	Underline:       0,
+	StrikeOut:       0,
+	ScaleX:          100.0,
+	ScaleY:          100.0,
+	Spacing:         0,
+	Angle:           0,
+	BorderStyle:     1,
+	Outline:         0,
+	Shadow:          0,
+	Alignment:       5,
+	MarginL:         10,
+	MarginR:         10,
+	MarginV:         10,
+	Encoding:        1,
+}
+var FullScreenTextStyleFormat = SubtitleStyleItem{
+	Name:            "fullscreen",
+	FontName:        "思源黑体 CN Bold",
+	Fontsize:        75,
+	PrimaryColour:   "&H00FFFFFF",
+	SecondaryColour: "&H000000FF",
+	OutlineColour:   "&H00000000",
+	BackColour:      "&H00000000",
+	Bold:            0,
+	Italic:          0,
+	Underline:       0,
+	StrikeOut:       0,
+	ScaleX:          100.0,
+	ScaleY:          100.0,
+	Spacing:         2,
+	Angle:           0,
+	BorderStyle:     1,
+	Outline:         0,
+	Shadow:          0,
+	Alignment:       5,
+	MarginL:         10,
+	MarginR:         10,
+	MarginV:         10,
+	Encoding:        1,
+}
 
 func DoZlibUnCompress(compressSrc []byte) []byte {
 	b := bytes.NewReader(compressSrc)
@@ -0,0 +1,187 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// CORRECTIONS
+// A corrections sidecar lists manual fixes for detected runs. Items address a
+// run by its kind and its index as logged during detection ("Dialog No.3"),
+// so the same file keeps applying when the task is rerun with new story data or styles.
+
+type CorrectionItem struct {
+	Kind       string `json:"kind"`
+	Index      int    `json:"index"`
+	StartFrame int    `json:"start_frame"`
+	EndFrame   int    `json:"end_frame"`
+	MergeNext  bool   `json:"merge_next"`
+	SplitAt    int    `json:"split_at"`
+	Drop       bool   `json:"drop"`
+	Line       int    `json:"line"`
+}
+
+type Corrections struct {
+	Items []CorrectionItem `json:"corrections"`
+}
+
+func (c Corrections) Count() int {
+	return len(c.Items)
+}
+func (c Corrections) of(kind string) map[int]CorrectionItem {
+	var result = map[int]CorrectionItem{}
+	for _, item := range c.Items {
+		if strings.EqualFold(item.Kind, kind) && item.Index > 0 {
+			result[item.Index-1] = item
+		}
+	}
+	return result
+}
+
+// correctionsPath is the configured sidecar or "<output>.corrections.json" next to the output file.
+func (t *Task) correctionsPath() string {
+	if t.Config.Corrections != "" {
+		return t.Config.Corrections
+	}
+	if t.Config.OutputPath == "" {
+		return ""
+	}
+	return strings.TrimSuffix(t.Config.OutputPath, ".ass") + ".corrections.json"
+}
+
+func (t *Task) loadCorrections() (result Corrections) {
+	file := t.correctionsPath()
+	if file == "" || !FileExist(file) {
+		return
+	}
+	dat, err := os.ReadFile(file)
+	if err == nil {
+		err = json.Unmarshal(dat, &result)
+	}
+	if err != nil {
+		go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Warning] Ignored Corrections File: %s", err.Error())})
+		return Corrections{}
+	}
+	go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Initial] Loaded %d Corrections", result.Count())})
+	return
+}
+
+type correctedRun[T any] struct {
+	frames []T
+	origin int
+}
+
+// applyRunCorrections edits the runs of one kind in a fixed order: forced start
+// and end frames, splits, merges with the next run, then drops. It returns the
+// corrected runs, the original index of every corrected run and the story
+// index forced onto corrected runs.
+func applyRunCorrections[T any](t *Task, kind string, runs [][]T, frameId func(T) int, withFrameId func(T, int) T) (
+	result [][]T, origins []int, lines map[int]int) {
+	var items = t.Corrections.of(kind)
+	lines = map[int]int{}
+	var entries []correctedRun[T]
+	for i, frames := range runs {
+		entries = append(entries, correctedRun[T]{frames: frames, origin: i})
+	}
+	var logApplied = func(item CorrectionItem, msg string) {
+		go t.Log(Log{Type: "string",
+			Data: fmt.Sprintf("[Processing] Corrected %s No.%d: %s", kind, item.Index, msg)})
+	}
+
+	for i, e := range entries {
+		item, ok := items[e.origin]
+		if !ok || (item.StartFrame == 0 && item.EndFrame == 0) {
+			continue
+		}
+		var frames = e.frames
+		if item.StartFrame > 0 {
+			first := frames[0]
+			var prefix []T
+			for id := item.StartFrame; id < frameId(first); id++ {
+				prefix = append(prefix, withFrameId(first, id))
+			}
+			var rest []T
+			for _, f := range frames {
+				if frameId(f) >= item.StartFrame {
+					rest = append(rest, f)
+				}
+			}
+			frames = append(prefix, rest...)
+		}
+		if item.EndFrame > 0 && len(frames) > 0 {
+			last := frames[len(frames)-1]
+			var rest []T
+			for _, f := range frames {
+				if frameId(f) <= item.EndFrame {
+					rest = append(rest, f)
+				}
+			}
+			for id := frameId(last) + 1; id <= item.EndFrame; id++ {
+				rest = append(rest, withFrameId(last, id))
+			}
+			frames = rest
+		}
+		entries[i].frames = frames
+		logApplied(item, fmt.Sprintf("Frames %d-%d", item.StartFrame, item.EndFrame))
+	}
+
+	var split []correctedRun[T]
+	for _, e := range entries {
+		item, ok := items[e.origin]
+		cut := 0
+		for ok && item.SplitAt > 0 && cut < len(e.frames) && frameId(e.frames[cut]) < item.SplitAt {
+			cut += 1
+		}
+		if cut == 0 || cut == len(e.frames) {
+			split = append(split, e)
+			continue
+		}
+		split = append(split, correctedRun[T]{frames: e.frames[:cut], origin: e.origin},
+			correctedRun[T]{frames: e.frames[cut:], origin: e.origin})
+		logApplied(item, fmt.Sprintf("Split at Frame %d", item.SplitAt))
+	}
+
+	var merged []correctedRun[T]
+	for i := 0; i < len(split); i++ {
+		e := split[i]
+		tail := e.origin
+		for {
+			item, ok := items[tail]
+			if !ok || !item.MergeNext || i+1 >= len(split) || split[i+1].origin == tail {
+				break
+			}
+			i += 1
+			e.frames = append(append([]T{}, e.frames...), split[i].frames...)
+			tail = split[i].origin
+			logApplied(item, "Merged With Next")
+		}
+		merged = append(merged, e)
+	}
+
+	var firstPiece = map[int]bool{}
+	for _, e := range merged {
+		item, ok := items[e.origin]
+		if ok && item.Drop {
+			if !firstPiece[e.origin] {
+				logApplied(item, "Dropped")
+			}
+			firstPiece[e.origin] = true
+			continue
+		}
+		if len(e.frames) == 0 {
+			go t.Log(Log{Type: "string",
+				Data: fmt.Sprintf("[Warning] Correction Left %s No.%d Without Frames", kind, e.origin+1)})
+			continue
+		}
+		if ok && item.Line > 0 && !firstPiece[e.origin] {
+			lines[len(result)] = item.Line - 1
+			logApplied(item, fmt.Sprintf("Assigned to Line %d", item.Line))
+		}
+		firstPiece[e.origin] = true
+		result = append(result, e.frames)
+		origins = append(origins, e.origin)
+	}
+	return
+}
@@ -15,8 +15,7 @@ type VoiceData struct {
 }
 
 func (v VoiceData) CharacterId() int {
-	var l2dCid = C2Did_to_Cid[v.Character2DId]
-	if l2dCid >= 1 && l2dCid <= 26 {
+	if l2dCid := Characters.CharacterId(v.Character2DId); l2dCid != 0 {
 		return l2dCid
 	}
 	s := strings.Split(v.VoiceId, "_")
@@ -33,18 +32,32 @@ func (v VoiceData) CharacterId() int {
 	return 0
 }
 
+// Snippet actions and the data array their ReferenceIndex points into. The other
+// actions of the game (3 InputName, 5 Selectable, 8 CharacterLayoutMode) are not checked.
+const (
+	SnippetTalk            = 1
+	SnippetCharacterLayout = 2
+	SnippetCharacterMotion = 4
+	SnippetSpecialEffect   = 6
+	SnippetSound           = 7
+)
+
 type SnippetItem struct {
-	Action int `json:"Action"`
-	// Index            int     `json:"Index"`
-	// ProgressBehavior int     `json:"ProgressBehavior"`
-	// ReferenceIndex   int     `json:"ReferenceIndex"`
-	// Delay            float64 `json:"Delay"`
+	Index            int     `json:"Index"`
+	Action           int     `json:"Action"`
+	ProgressBehavior int     `json:"ProgressBehavior"`
+	ReferenceIndex   int     `json:"ReferenceIndex"`
+	Delay            float64 `json:"Delay"`
+}
+type TalkCharacter struct {
+	Character2DId int `json:"Character2dId"`
 }
 type TalkDataItem struct {
-	WindowDisplayName     string      `json:"WindowDisplayName"`
-	Body                  string      `json:"Body"`
-	WhenFinishCloseWindow int         `json:"WhenFinishCloseWindow"`
-	Voices                []VoiceData `json:"Voices"`
+	TalkCharacters        []TalkCharacter `json:"TalkCharacters"`
+	WindowDisplayName     string          `json:"WindowDisplayName"`
+	Body                  string          `json:"Body"`
+	WhenFinishCloseWindow int             `json:"WhenFinishCloseWindow"`
+	Voices                []VoiceData     `json:"Voices"`
 }
 
 func (t TalkDataItem) CharacterId() int {
@@ -53,55 +66,282 @@ func (t TalkDataItem) CharacterId() int {
 			return t.Voices[0].CharacterId()
 		}
 	}
+	if len(t.Voices) == 0 && len(t.TalkCharacters) == 1 {
+		return Characters.CharacterId(t.TalkCharacters[0].Character2DId)
+	}
+	return 0
+}
+
+// Character2DId is the Live2D id of the speaker of a line, or 0 when several characters speak.
+func (t TalkDataItem) Character2DId() int {
+	if len(t.Voices) == 1 && t.Voices[0].Character2DId != 0 {
+		return t.Voices[0].Character2DId
+	}
+	if len(t.Voices) <= 1 && len(t.TalkCharacters) == 1 {
+		return t.TalkCharacters[0].Character2DId
+	}
 	return 0
 }
 
+// VoiceId lists the voice ids of a line, joined with "|" when several characters speak.
+func (t TalkDataItem) VoiceId() string {
+	var ids []string
+	for _, v := range t.Voices {
+		if v.VoiceId != "" {
+			ids = append(ids, v.VoiceId)
+		}
+	}
+	return strings.Join(ids, "|")
+}
+
 type SpecialEffectDataItem struct {
-	EffectType int    `json:"EffectType"`
-	StringVal  string `json:"StringVal"`
-	// StringValSub string  `json:"StringValSub"`
-	// Duration     float64 `json:"Duration"`
-	// IntVal       int     `json:"IntVal"`
+	EffectType   int     `json:"EffectType"`
+	StringVal    string  `json:"StringVal"`
+	StringValSub string  `json:"StringValSub"`
+	Duration     float64 `json:"Duration"`
+	IntVal       int     `json:"IntVal"`
+}
+
+// StoryEffectTypes maps the SpecialEffectData EffectType values kept by Clean to story event types.
+// These are all the effects showing their StringVal as text: 8 is the telop
+// and 18 the place info of the game. Flashbacks (9, 10) have no text.
+var StoryEffectTypes = map[int]string{
+	8:  "Banner",
+	18: "Marker",
+	23: "Choice",
+	24: "FullScreenText",
+}
+
+// storyAssetEffectTypes are the effects whose StringVal names an asset rather
+// than text: backgrounds (7, 17), card stills (11), scenario effects (15, 16)
+// and movies (19).
+var storyAssetEffectTypes = map[int]bool{7: true, 11: true, 15: true, 16: true, 17: true, 19: true}
+
+func storyEffectTypeNames() []string {
+	var result []string
+	for _, t := range StoryEffectTypes {
+		result = append(result, t)
+	}
+	return result
+}
+
+type AppearCharacter struct {
+	Character2DId int    `json:"Character2dId"`
+	CostumeType   string `json:"CostumeType"`
+}
+type LayoutDataItem struct {
+	Type            int    `json:"Type"`
+	SideFrom        int    `json:"SideFrom"`
+	SideFromOffsetX int    `json:"SideFromOffsetX"`
+	SideTo          int    `json:"SideTo"`
+	SideToOffsetX   int    `json:"SideToOffsetX"`
+	DepthType       int    `json:"DepthType"`
+	Character2DId   int    `json:"Character2dId"`
+	CostumeType     string `json:"CostumeType"`
+	MotionName      string `json:"MotionName"`
+	FacialName      string `json:"FacialName"`
+	MoveSpeedType   int    `json:"MoveSpeedType"`
+}
+type SoundDataItem struct {
+	PlayMode     int     `json:"PlayMode"`
+	Bgm          string  `json:"Bgm"`
+	Se           string  `json:"Se"`
+	Volume       float64 `json:"Volume"`
+	SeBundleName string  `json:"SeBundleName"`
+	Duration     float64 `json:"Duration"`
 }
+
 type GameStoryData struct {
+	ScenarioId        string                  `json:"ScenarioId"`
+	Title             string                  `json:"Title"`
+	FirstBgm          string                  `json:"FirstBgm"`
+	FirstBackground   string                  `json:"FirstBackground"`
+	AppearCharacters  []AppearCharacter       `json:"AppearCharacters"`
 	TalkData          []TalkDataItem          `json:"TalkData"`
 	Snippets          []SnippetItem           `json:"Snippets"`
 	SpecialEffectData []SpecialEffectDataItem `json:"SpecialEffectData"`
+	LayoutData        []LayoutDataItem        `json:"LayoutData"`
+	SoundData         []SoundDataItem         `json:"SoundData"`
+	Issues            []string                `json:"-"`
+}
+
+func (s *GameStoryData) referenceCount(action int) int {
+	switch action {
+	case SnippetTalk:
+		return len(s.TalkData)
+	case SnippetCharacterLayout, SnippetCharacterMotion:
+		return len(s.LayoutData)
+	case SnippetSpecialEffect:
+		return len(s.SpecialEffectData)
+	case SnippetSound:
+		return len(s.SoundData)
+	}
+	return -1
+}
+
+// references resolves every snippet to an index of its data array. Older assets
+// leave ReferenceIndex at 0, so their snippets are counted in order instead;
+// references outside their array are reported and resolve to -1.
+func (s *GameStoryData) references() []int {
+	var counted = map[int]int{}
+	var useIndex = false
+	for _, snippet := range s.Snippets {
+		if snippet.ReferenceIndex != 0 {
+			useIndex = true
+		}
+	}
+	var result []int
+	for i, snippet := range s.Snippets {
+		ref := snippet.ReferenceIndex
+		if !useIndex {
+			ref = counted[snippet.Action]
+		}
+		counted[snippet.Action] += 1
+		if count := s.referenceCount(snippet.Action); count >= 0 && (ref < 0 || ref >= count) {
+			s.Issues = append(s.Issues, fmt.Sprintf("Snippet %d (Action %d) References Missing Item %d of %d",
+				i, snippet.Action, ref, count))
+			ref = -1
+		}
+		result = append(result, ref)
+	}
+	for _, action := range []int{SnippetTalk, SnippetSpecialEffect} {
+		if count := s.referenceCount(action); counted[action] != count {
+			s.Issues = append(s.Issues, fmt.Sprintf("%d Snippets of Action %d for %d Items",
+				counted[action], action, count))
+		}
+	}
+	return result
 }
 
+// Clean keeps the talk snippets and the snippets of story effects, and reorders
+// TalkData and SpecialEffectData to follow them, so the n-th snippet of an
+// action uses the n-th item of its array.
 func (s *GameStoryData) Clean() {
 	var sn []SnippetItem
-	seCount := 0
-	for _, snippet := range s.Snippets {
-		if snippet.Action == 1 {
+	var td []TalkDataItem
+	var se []SpecialEffectDataItem
+	refs := s.references()
+	for i, snippet := range s.Snippets {
+		if refs[i] < 0 {
+			continue
+		}
+		if snippet.Action == SnippetTalk {
+			snippet.ReferenceIndex = len(td)
+			td = append(td, s.TalkData[refs[i]])
 			sn = append(sn, snippet)
-		} else if snippet.Action == 6 {
-			seData := s.SpecialEffectData[seCount]
-			if seData.EffectType == 8 || seData.EffectType == 18 {
+		} else if snippet.Action == SnippetSpecialEffect {
+			seData := s.SpecialEffectData[refs[i]]
+			if _, ok := StoryEffectTypes[seData.EffectType]; ok {
+				snippet.ReferenceIndex = len(se)
+				se = append(se, seData)
 				sn = append(sn, snippet)
+			} else if seData.StringVal != "" && !storyAssetEffectTypes[seData.EffectType] {
+				s.Issues = append(s.Issues, fmt.Sprintf("Effect Type %d With Text %q Is Not Supported",
+					seData.EffectType, seData.StringVal))
 			}
-			seCount += 1
-		}
-	}
-	s.Snippets = sn
-	var se []SpecialEffectDataItem
-	for _, datum := range s.SpecialEffectData {
-		if datum.EffectType == 8 || datum.EffectType == 18 {
-			se = append(se, datum)
 		}
 	}
-	s.SpecialEffectData = se
+	s.Snippets, s.TalkData, s.SpecialEffectData = sn, td, se
 }
 
 func (s *GameStoryData) Empty() bool {
 	return len(s.Snippets)+len(s.SpecialEffectData)+len(s.TalkData) == 0
 }
 
+// StoryMetadata describes the scenario a story was made from.
+type StoryMetadata struct {
+	ScenarioId   string
+	Title        string
+	FirstBgm     string
+	CharacterIds []int
+	// Character2DIds are the Live2D ids of the appearing characters.
+	Character2DIds []int
+	Issues         []string
+}
+
+func (s *GameStoryData) Metadata() StoryMetadata {
+	var result = StoryMetadata{ScenarioId: s.ScenarioId, Title: s.Title, FirstBgm: s.FirstBgm, Issues: s.Issues}
+	if result.Title == "" {
+		result.Title = s.ScenarioId
+	}
+	var seen = map[int]bool{}
+	for _, c := range s.AppearCharacters {
+		if cid := Characters.CharacterId(c.Character2DId); cid != 0 && !seen[cid] {
+			seen[cid] = true
+			result.CharacterIds = append(result.CharacterIds, cid)
+			result.Character2DIds = append(result.Character2DIds, c.Character2DId)
+		}
+	}
+	return result
+}
+
+// Unit is the unit most of the appearing characters belong to.
+func (m StoryMetadata) Unit() string {
+	var counts = map[string]int{}
+	var result string
+	for _, l2d := range m.Character2DIds {
+		unit := Characters.Unit(l2d)
+		counts[unit] += 1
+		if unit != "" && (counts[unit] > counts[result] || (counts[unit] == counts[result] && unit < result)) {
+			result = unit
+		}
+	}
+	return result
+}
+
+// fileNameReplacer replaces the characters that are not allowed in file names on Windows.
+var fileNameReplacer = strings.NewReplacer(
+	"/", "_", "\\", "_", ":", "_", "*", "_", "?", "_", "\"", "_", "<", "_", ">", "_", "|", "_",
+	"\r", "", "\n", "", "\t", " ")
+
+func fileNameSafe(s string) string {
+	return strings.TrimRight(strings.TrimSpace(fileNameReplacer.Replace(s)), ".")
+}
+
+// OutputName fills the {scenario}, {title} and {unit} placeholders of an output path
+// with file name safe values. Placeholders without a value are removed and returned.
+func (m StoryMetadata) OutputName(path string) (string, []string) {
+	var pairs, missing []string
+	for _, p := range [][2]string{{"{scenario}", m.ScenarioId}, {"{title}", m.Title}, {"{unit}", m.Unit()}} {
+		value := fileNameSafe(p[1])
+		if value == "" && strings.Contains(path, p[0]) {
+			missing = append(missing, p[0])
+		}
+		pairs = append(pairs, p[0], value)
+	}
+	return strings.NewReplacer(pairs...).Replace(path), missing
+}
+
+// scenarioWrappers are the keys asset dumps nest the scenario object under.
+var scenarioWrappers = []string{"MonoBehaviour", "Base", "data"}
+
+func unwrapScenario(dat []byte) []byte {
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(dat, &fields) != nil {
+		return dat
+	}
+	for key := range fields {
+		if strings.EqualFold(key, "Snippets") || strings.EqualFold(key, "TalkData") {
+			return dat
+		}
+	}
+	for _, wrapper := range scenarioWrappers {
+		for key, value := range fields {
+			if strings.EqualFold(key, wrapper) {
+				return unwrapScenario(value)
+			}
+		}
+	}
+	return dat
+}
+
 func ReadJson(file string) GameStoryData {
 	var result GameStoryData
 	if FileExist(file) {
 		dat, _ := os.ReadFile(file)
-		_ = json.Unmarshal(dat, &result)
+		if err := json.Unmarshal(unwrapScenario(dat), &result); err != nil {
+			result.Issues = append(result.Issues, err.Error())
+		}
 		result.Clean()
 	}
 	return result
@@ -201,6 +441,9 @@ type StoryEvent struct {
 	CharacterT  string
 	ContentO    string
 	ContentT    string
+	VoiceId     string
+	// Character2DId is not part of PJS lines, events read from them have none.
+	Character2DId int
 }
 type EventContent struct {
 	Body      string
@@ -221,15 +464,43 @@ func (s StoryEvent) Content() EventContent {
 	}
 	return EventContent{body, chara}
 }
+
+// character2DId is the Live2D id the speaker is looked up with, falling back
+// to the first one of the character for events read from PJS lines and for
+// speakers only known by their voice id.
+func (s StoryEvent) character2DId() int {
+	if Characters.Style(s.Character2DId) != "" {
+		return s.Character2DId
+	}
+	return Characters.Character2DId(s.CharacterId)
+}
+
+// String is the PJS line of an event:
+//
+//	Type,CharacterId,CharacterO,CharacterT,ContentO,ContentT[,VoiceId]
+//
+// The seventh VoiceId field is only written when the event has voices, so
+// readers splitting on commas have to accept six or seven fields.
 func (s StoryEvent) String() string {
-	return fmt.Sprintf("%s,%02d,%s,%s,%s,%s",
+	var result = fmt.Sprintf("%s,%02d,%s,%s,%s,%s",
 		s.Type,
 		s.CharacterId,
 		s.CharacterO,
 		s.CharacterT,
 		s.ContentO,
 		s.ContentT)
+	if s.VoiceId != "" {
+		result += "," + s.VoiceId
+	}
+	return result
 }
+
+// pjsVoiceIdReg matches the VoiceId field of a PJS line, so a translation with
+// commas in it is not mistaken for one.
+var pjsVoiceIdReg = regexp.MustCompile(`^\w+_\w+(\|\w+_\w+)*$`)
+
+// EventFromString reads a PJS line. Fields past the sixth are kept in ContentT
+// unless the last one is a voice id.
 func EventFromString(s string) StoryEvent {
 	sArr := strings.Split(s, ",")
 
@@ -239,8 +510,13 @@ func EventFromString(s string) StoryEvent {
 		CharacterO:  sArr[2],
 		CharacterT:  sArr[3],
 		ContentO:    sArr[4],
-		ContentT:    sArr[5],
 	}
+	content := sArr[5:]
+	if last := strings.TrimRight(content[len(content)-1], "\r"); len(content) > 1 && pjsVoiceIdReg.MatchString(last) {
+		result.VoiceId = last
+		content = content[:len(content)-1]
+	}
+	result.ContentT = strings.Join(content, ",")
 	return result
 }
 
@@ -279,6 +555,7 @@ func (s StoryEventSet) IndexTypes(ts []string, i int) int {
 
 type PJSTranslationData struct {
 	Data StoryEventSet `yaml:"内容"`
+	Meta StoryMetadata `yaml:"-"`
 }
 
 func (y PJSTranslationData) get(t []string) StoryEventSet {
@@ -296,8 +573,93 @@ func (y PJSTranslationData) get(t []string) StoryEventSet {
 func (y PJSTranslationData) Dialogs() StoryEventSet { return y.get([]string{"Dialog"}) }
 func (y PJSTranslationData) Banners() StoryEventSet { return y.get([]string{"Banner"}) }
 func (y PJSTranslationData) Markers() StoryEventSet { return y.get([]string{"Marker"}) }
-func (y PJSTranslationData) Effects() StoryEventSet { return y.get([]string{"Banner", "Marker"}) }
+func (y PJSTranslationData) Choices() StoryEventSet { return y.get([]string{"Choice"}) }
+func (y PJSTranslationData) FullScreenTexts() StoryEventSet {
+	return y.get([]string{"FullScreenText"})
+}
+func (y PJSTranslationData) Effects() StoryEventSet { return y.get(storyEffectTypeNames()) }
 func (y PJSTranslationData) DPeriod() StoryEventSet { return y.get([]string{"Dialog", "Period"}) }
+
+// DialogOpensBox reports whether the i-th dialog is the first one shown after the box was closed.
+func (y PJSTranslationData) DialogOpensBox(i int) bool {
+	d := y.DPeriod()
+	index := d.IndexType("Dialog", i)
+	if index < 0 {
+		return false
+	}
+	return index == 0 || d[index-1].Type == "Period"
+}
+
+// DialogClosesBox reports whether the box closes after the i-th dialog.
+func (y PJSTranslationData) DialogClosesBox(i int) bool {
+	d := y.DPeriod()
+	index := d.IndexType("Dialog", i)
+	if index < 0 {
+		return false
+	}
+	return index == len(d)-1 || d[index+1].Type == "Period"
+}
+
+// ChoicePrompts groups consecutive Choice events, one group per on-screen prompt.
+func (y PJSTranslationData) ChoicePrompts() []StoryEventSet {
+	var result []StoryEventSet
+	for i, datum := range y.Data {
+		if datum.Type != "Choice" {
+			continue
+		}
+		if i == 0 || y.Data[i-1].Type != "Choice" {
+			result = append(result, StoryEventSet{})
+		}
+		result[len(result)-1] = append(result[len(result)-1], datum)
+	}
+	return result
+}
+
+// segmentCount is the number of detector runs expected for an event type.
+func (y PJSTranslationData) segmentCount(t string) int {
+	if t == "Choice" {
+		return len(y.ChoicePrompts())
+	}
+	return y.get([]string{t}).Count()
+}
+
+// segmentIndex is the position in Data where the i-th detector run of an event type starts.
+func (y PJSTranslationData) segmentIndex(t string, i int) int {
+	if t != "Choice" {
+		return y.Data.IndexType(t, i)
+	}
+	promptCount := 0
+	for i2, datum := range y.Data {
+		if datum.Type == "Choice" && (i2 == 0 || y.Data[i2-1].Type != "Choice") {
+			if promptCount == i {
+				return i2
+			}
+			promptCount += 1
+		}
+	}
+	return -1
+}
+
+// FilterEffects drops the effect events whose EffectType is not listed,
+// keeping dialogs and periods. An empty list keeps every effect.
+func (y PJSTranslationData) FilterEffects(effectTypes []int) PJSTranslationData {
+	if len(effectTypes) == 0 {
+		return y
+	}
+	var enabled = map[string]bool{"Dialog": true, "Period": true}
+	for _, effectType := range effectTypes {
+		if t, ok := StoryEffectTypes[effectType]; ok {
+			enabled[t] = true
+		}
+	}
+	var result = PJSTranslationData{Data: StoryEventSet{}, Meta: y.Meta}
+	for _, datum := range y.Data {
+		if enabled[datum.Type] {
+			result.Data = append(result.Data, datum)
+		}
+	}
+	return result
+}
 func (y PJSTranslationData) String() string {
 	var s []string
 	for _, datum := range y.Data {
@@ -330,19 +692,21 @@ func ReadPJSFile(filename string) PJSTranslationData {
 func MakePJSData(jsonFile, textFile string) PJSTranslationData {
 	jsonData := ReadJson(jsonFile)
 	textData := ReadText(textFile)
-	result := PJSTranslationData{}
+	result := PJSTranslationData{Meta: jsonData.Metadata()}
 	if !jsonData.Empty() {
 		dialogCount := 0
 		effectCount := 0
 		for _, snippet := range jsonData.Snippets {
-			if snippet.Action == 1 {
-				dialogData := jsonData.TalkData[dialogCount]
+			if snippet.Action == SnippetTalk {
 				if dialogCount < len(jsonData.TalkData) {
+					dialogData := jsonData.TalkData[dialogCount]
 					s := StoryEvent{
-						Type:        "Dialog",
-						CharacterId: dialogData.CharacterId(),
-						CharacterO:  dialogData.WindowDisplayName,
-						ContentO:    strings.ReplaceAll(dialogData.Body, "\n", "\\N"),
+						Type:          "Dialog",
+						CharacterId:   dialogData.CharacterId(),
+						CharacterO:    dialogData.WindowDisplayName,
+						ContentO:      strings.ReplaceAll(dialogData.Body, "\n", "\\N"),
+						VoiceId:       dialogData.VoiceId(),
+						Character2DId: dialogData.Character2DId(),
 					}
 					result.Data = append(result.Data, s)
 					if dialogData.WhenFinishCloseWindow == 1 {
@@ -351,20 +715,20 @@ func MakePJSData(jsonFile, textFile string) PJSTranslationData {
 				}
 				dialogCount += 1
 			}
-			if snippet.Action == 6 {
+			if snippet.Action == SnippetSpecialEffect && effectCount < len(jsonData.SpecialEffectData) {
 				effectData := jsonData.SpecialEffectData[effectCount]
-				var t string
-				if effectData.EffectType == 8 {
-					t = "Banner"
-				}
-				if effectData.EffectType == 18 {
-					t = "Marker"
-				}
-				s := StoryEvent{
-					Type:     t,
-					ContentO: effectData.StringVal,
+				t := StoryEffectTypes[effectData.EffectType]
+				if t == "Choice" {
+					for _, option := range ArrSplit(strings.Split(effectData.StringVal, "\n")) {
+						result.Data = append(result.Data, StoryEvent{Type: t, ContentO: option})
+					}
+				} else {
+					s := StoryEvent{
+						Type:     t,
+						ContentO: strings.ReplaceAll(effectData.StringVal, "\n", "\\N"),
+					}
+					result.Data = append(result.Data, s)
 				}
-				result.Data = append(result.Data, s)
 				effectCount += 1
 			}
 		}
@@ -379,12 +743,11 @@ func MakePJSData(jsonFile, textFile string) PJSTranslationData {
 				}
 			}
 		}
+		// Legacy text files only ever list banners and markers as effects.
 		for i, effect := range textData.Effects {
-			if i < result.Effects().Count() {
-				iT := result.Data.IndexTypes([]string{"Banner", "Marker"}, i)
-				if iT >= 0 {
-					result.Data[iT].ContentT = effect.Body
-				}
+			iT := result.Data.IndexTypes([]string{"Banner", "Marker"}, i)
+			if iT >= 0 {
+				result.Data[iT].ContentT = effect.Body
 			}
 		}
 	}
@@ -0,0 +1,432 @@
+package process
+
+import (
+	"image"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+// Detector locates one kind of on-screen element frame by frame and groups
+// the frames it appears in into runs (segments).
+//
+// Init receives the video frame size before the first frame, Process is called
+// with a grayscale frame owned by the detector for the duration of the call,
+// Finalize flushes a run that is still open when the scan ends, and Close
+// releases the templates allocated by Init.
+type Detector interface {
+	Kind() string
+	Init(h, w int)
+	Process(frame gocv.Mat, frameId int)
+	Segments() int
+	Finalize()
+	Close()
+}
+
+// segmentRecorder is the run bookkeeping shared by the detectors.
+type segmentRecorder[T any] struct {
+	kind       string
+	processing []T
+	segments   [][]T
+	onSegment  func(kind string, index, length int)
+}
+
+func (r *segmentRecorder[T]) Kind() string  { return r.kind }
+func (r *segmentRecorder[T]) Segments() int { return len(r.segments) }
+func (r *segmentRecorder[T]) push(frame T) {
+	r.processing = append(r.processing, frame)
+}
+func (r *segmentRecorder[T]) emit() {
+	if len(r.processing) == 0 {
+		return
+	}
+	r.segments = append(r.segments, r.processing)
+	if r.onSegment != nil {
+		r.onSegment(r.kind, len(r.segments), len(r.processing))
+	}
+	r.processing = nil
+}
+
+// DIALOG
+type dialogDetector struct {
+	segmentRecorder[dialogFrame]
+	threshold        float64
+	height, width    int
+	pointer          gocv.Mat
+	runSignature     []uint8
+	signatures       [][]uint8
+	lastStatus       uint8
+	lastPointCenter  image.Point
+	constPointCenter image.Point
+}
+
+func newDialogDetector(threshold float64, onSegment func(string, int, int)) *dialogDetector {
+	return &dialogDetector{
+		segmentRecorder: segmentRecorder[dialogFrame]{kind: "Dialog", onSegment: onSegment},
+		threshold:       threshold,
+	}
+}
+func (d *dialogDetector) Init(h, w int) {
+	d.height, d.width = h, w
+	d.pointer = getResizedDialogPointer(h, w)
+}
+func (d *dialogDetector) PointSize() int {
+	return d.pointer.Cols()
+}
+func (d *dialogDetector) Process(frame gocv.Mat, frameId int) {
+	result := matchFrameDialog(frame, d.pointer, d.lastPointCenter, d.threshold)
+	if d.constPointCenter.Eq(image.Point{}) && result.status == 2 {
+		d.constPointCenter = result.pointCenter
+	}
+	if result.status != 2 && d.lastStatus == 2 {
+		d.emitRun()
+	}
+	if result.status == 2 && d.runSignature == nil {
+		d.runSignature = getFrameAreaSignature(frame,
+			getDialogCharacterArea(d.height, d.width, result.pointCenter, d.PointSize()))
+	}
+	if result.status != 0 {
+		d.push(dialogFrame{FrameId: frameId, PointCenter: result.pointCenter})
+	}
+	d.lastStatus = result.status
+	d.lastPointCenter = result.pointCenter
+}
+
+// emitRun emits the current run along with the name plate signature taken at its first complete frame.
+func (d *dialogDetector) emitRun() {
+	d.signatures = append(d.signatures, d.runSignature)
+	d.runSignature = nil
+	d.emit()
+}
+func (d *dialogDetector) Finalize() {
+	if d.lastStatus == 2 {
+		d.emitRun()
+	}
+	d.processing = nil
+}
+func (d *dialogDetector) Close() {
+	_ = d.pointer.Close()
+}
+
+// BANNER
+type bannerDetector struct {
+	segmentRecorder[bannerFrame]
+	threshold  float64
+	canny      gocv.Mat
+	reverse    gocv.Mat
+	area       [4]int
+	lastResult bool
+}
+
+func newBannerDetector(threshold float64, onSegment func(string, int, int)) *bannerDetector {
+	return &bannerDetector{
+		segmentRecorder: segmentRecorder[bannerFrame]{kind: "Banner", onSegment: onSegment},
+		threshold:       threshold,
+	}
+}
+func (d *bannerDetector) Init(h, w int) {
+	d.canny, d.reverse, d.area = getBannerEdgeTemplates(h, w)
+}
+func (d *bannerDetector) Process(frame gocv.Mat, frameId int) {
+	result := matchFrameBanner(frame, d.canny, d.reverse, d.area, d.threshold)
+	if result {
+		d.push(bannerFrame{FrameId: frameId})
+	}
+	if d.lastResult && !result {
+		d.emit()
+	}
+	d.lastResult = result
+}
+func (d *bannerDetector) Finalize() {
+	if d.lastResult {
+		d.emit()
+	}
+}
+func (d *bannerDetector) Close() {
+	_ = d.canny.Close()
+	_ = d.reverse.Close()
+}
+
+// MARKER
+type markerDetector struct {
+	segmentRecorder[markerFrame]
+	threshold  float64
+	marker     gocv.Mat
+	lastResult image.Point
+}
+
+func newMarkerDetector(threshold float64, onSegment func(string, int, int)) *markerDetector {
+	return &markerDetector{
+		segmentRecorder: segmentRecorder[markerFrame]{kind: "Marker", onSegment: onSegment},
+		threshold:       threshold,
+	}
+}
+func (d *markerDetector) Init(h, w int) {
+	d.marker = getResizedAreaMarkerTemplate(h, w)
+}
+func (d *markerDetector) Process(frame gocv.Mat, frameId int) {
+	result := matchFrameMarker(frame, d.marker, d.threshold)
+	if !result.Eq(image.Point{}) {
+		d.push(markerFrame{Position: result, FrameId: frameId})
+	}
+	if !d.lastResult.Eq(image.Point{}) && result.Eq(image.Point{}) {
+		d.emit()
+	}
+	d.lastResult = result
+}
+func (d *markerDetector) Finalize() {
+	if !d.lastResult.Eq(image.Point{}) {
+		d.emit()
+	}
+}
+func (d *markerDetector) Close() {
+	_ = d.marker.Close()
+}
+
+// MENU
+// menuDetector emits a single one-frame segment at the first frame showing the
+// story menu sign, which marks the start of the story content.
+type menuDetector struct {
+	segmentRecorder[int]
+	threshold float64
+	menuSign  gocv.Mat
+}
+
+func newMenuDetector(threshold float64, onSegment func(string, int, int)) *menuDetector {
+	return &menuDetector{
+		segmentRecorder: segmentRecorder[int]{kind: "Menu", onSegment: onSegment},
+		threshold:       threshold,
+	}
+}
+func (d *menuDetector) Init(h, w int) {
+	d.menuSign = getResizedInterfaceMenu(h, w)
+}
+func (d *menuDetector) Started() bool {
+	return d.Segments() > 0
+}
+func (d *menuDetector) StartFrame() int {
+	if !d.Started() {
+		return 0
+	}
+	return d.segments[0][0]
+}
+func (d *menuDetector) Process(frame gocv.Mat, frameId int) {
+	if d.Started() {
+		return
+	}
+	if matchCheckStart(frame, d.menuSign, d.threshold) {
+		d.push(frameId)
+		d.emit()
+	}
+}
+func (d *menuDetector) Finalize() {}
+func (d *menuDetector) Close() {
+	_ = d.menuSign.Close()
+}
+
+// CHOICE
+type choiceDetector struct {
+	segmentRecorder[choiceFrame]
+	lastCount int
+}
+
+func newChoiceDetector(onSegment func(string, int, int)) *choiceDetector {
+	return &choiceDetector{
+		segmentRecorder: segmentRecorder[choiceFrame]{kind: "Choice", onSegment: onSegment},
+	}
+}
+func (d *choiceDetector) Init(h, w int) {}
+func (d *choiceDetector) Process(frame gocv.Mat, frameId int) {
+	buttons := checkFrameChoiceButtons(frame)
+	if len(buttons) > 0 {
+		d.push(choiceFrame{FrameId: frameId, Buttons: buttons})
+	}
+	if d.lastCount > 0 && len(buttons) == 0 {
+		d.emit()
+	}
+	d.lastCount = len(buttons)
+}
+func (d *choiceDetector) Finalize() {
+	if d.lastCount > 0 {
+		d.emit()
+	}
+}
+func (d *choiceDetector) Close() {}
+
+// FULL SCREEN TEXT
+type fullScreenTextDetector struct {
+	segmentRecorder[fullScreenTextFrame]
+	lastResult bool
+}
+
+func newFullScreenTextDetector(onSegment func(string, int, int)) *fullScreenTextDetector {
+	return &fullScreenTextDetector{
+		segmentRecorder: segmentRecorder[fullScreenTextFrame]{kind: "FullScreenText", onSegment: onSegment},
+	}
+}
+func (d *fullScreenTextDetector) Init(h, w int) {}
+func (d *fullScreenTextDetector) Process(frame gocv.Mat, frameId int) {
+	result, bounds := checkFrameFullScreenText(frame)
+	if result {
+		d.push(fullScreenTextFrame{FrameId: frameId, Bounds: bounds})
+	}
+	if d.lastResult && !result {
+		d.emit()
+	}
+	d.lastResult = result
+}
+func (d *fullScreenTextDetector) Finalize() {
+	if d.lastResult {
+		d.emit()
+	}
+}
+func (d *fullScreenTextDetector) Close() {}
+
+// DIALOG BOX
+// dialogBoxDetector follows the brightness coverage of the dialog box area and
+// emits one run per time the box is shown, including its open and close animations.
+type dialogBoxDetector struct {
+	segmentRecorder[dialogBoxFrame]
+	height, width int
+	anchor        image.Point
+	open          bool
+}
+
+const dialogBoxOpenCoverage = 0.3
+
+func newDialogBoxDetector(onSegment func(string, int, int)) *dialogBoxDetector {
+	return &dialogBoxDetector{
+		segmentRecorder: segmentRecorder[dialogBoxFrame]{kind: "DialogBox", onSegment: onSegment},
+	}
+}
+func (d *dialogBoxDetector) Init(h, w int) {
+	d.height, d.width = h, w
+}
+
+// SetAnchor places the measured area on the box once the dialog pointer position is known.
+func (d *dialogBoxDetector) SetAnchor(pointCenter image.Point) {
+	d.anchor = pointCenter
+}
+func (d *dialogBoxDetector) Pending() bool {
+	return d.open
+}
+func (d *dialogBoxDetector) Process(frame gocv.Mat, frameId int) {
+	coverage := checkFrameDialogBoxCoverage(frame, getDialogBoxArea(d.height, d.width, d.anchor))
+	result := coverage >= dialogBoxOpenCoverage
+	if result {
+		d.push(dialogBoxFrame{FrameId: frameId, Coverage: coverage})
+	}
+	if d.open && !result {
+		d.emit()
+	}
+	d.open = result
+}
+func (d *dialogBoxDetector) Finalize() {
+	if d.open {
+		d.emit()
+	}
+	d.open = false
+}
+func (d *dialogBoxDetector) Close() {}
+
+// DIALOG BOUNDARY
+// dialogBoundaryDetector watches the name plate and the text area while the
+// dialog pointer is shown and records the frames where a new line starts
+// without the pointer disappearing in between.
+type dialogBoundaryDetector struct {
+	segmentRecorder[dialogBoundary]
+	height, width int
+	pointSize     int
+	anchor        image.Point
+	reference     []uint8
+	changed       int
+	peakInk       float64
+}
+
+const (
+	dialogBoundaryPlateDistance = 0.08
+	dialogBoundaryPlateFrames   = 2
+	dialogBoundaryMinInk        = 0.005
+	dialogBoundaryResetRatio    = 0.4
+)
+
+func newDialogBoundaryDetector(onSegment func(string, int, int)) *dialogBoundaryDetector {
+	return &dialogBoundaryDetector{
+		segmentRecorder: segmentRecorder[dialogBoundary]{kind: "DialogBoundary", onSegment: onSegment},
+	}
+}
+func (d *dialogBoundaryDetector) Init(h, w int) {
+	d.height, d.width = h, w
+}
+
+// SetAnchor follows the dialog pointer, a zero point means the pointer is not shown.
+func (d *dialogBoundaryDetector) SetAnchor(pointCenter image.Point, pointSize int) {
+	d.anchor, d.pointSize = pointCenter, pointSize
+}
+func (d *dialogBoundaryDetector) Process(frame gocv.Mat, frameId int) {
+	if d.anchor.Eq(image.Point{}) {
+		d.reference, d.changed, d.peakInk = nil, 0, 0
+		return
+	}
+	signature := getFrameAreaSignature(frame, getDialogCharacterArea(d.height, d.width, d.anchor, d.pointSize))
+	ink := checkFrameDialogTextInk(frame, getDialogTextArea(d.height, d.width, d.anchor))
+	if d.reference == nil {
+		d.reference, d.peakInk = signature, ink
+		return
+	}
+	if signatureDistance(d.reference, signature) > dialogBoundaryPlateDistance {
+		d.changed += 1
+	} else {
+		d.changed = 0
+	}
+	switch {
+	case d.changed >= dialogBoundaryPlateFrames:
+		d.push(dialogBoundary{FrameId: frameId - d.changed + 1, Reason: "Name Plate Changed", Signature: signature})
+		d.emit()
+		d.reference, d.changed, d.peakInk = signature, 0, ink
+	case d.peakInk > dialogBoundaryMinInk && ink < d.peakInk*dialogBoundaryResetRatio:
+		d.push(dialogBoundary{FrameId: frameId, Reason: "Text Reset", Signature: signature})
+		d.emit()
+		d.peakInk = ink
+	default:
+		d.peakInk = math.Max(d.peakInk, ink)
+	}
+}
+func (d *dialogBoundaryDetector) Finalize() {}
+func (d *dialogBoundaryDetector) Close()    {}
+
+// DIALOG TEXT REVEAL
+// dialogRevealDetector samples how much text is drawn in the dialog body on every
+// frame once the pointer position is known. The samples are looked up by the
+// frames of the dialog runs, so they are kept as a single run.
+type dialogRevealDetector struct {
+	segmentRecorder[revealSample]
+	height, width int
+	anchor        image.Point
+}
+
+func newDialogRevealDetector(onSegment func(string, int, int)) *dialogRevealDetector {
+	return &dialogRevealDetector{
+		segmentRecorder: segmentRecorder[revealSample]{kind: "DialogReveal", onSegment: onSegment},
+	}
+}
+func (d *dialogRevealDetector) Init(h, w int) {
+	d.height, d.width = h, w
+}
+
+// SetAnchor places the text area on the box, like dialogBoxDetector it follows the
+// pointer position of the first complete dialog so the current frame is measured
+// without waiting for its own pointer match.
+func (d *dialogRevealDetector) SetAnchor(pointCenter image.Point) {
+	d.anchor = pointCenter
+}
+func (d *dialogRevealDetector) Process(frame gocv.Mat, frameId int) {
+	if d.anchor.Eq(image.Point{}) {
+		return
+	}
+	d.push(revealSample{FrameId: frameId, Ink: checkFrameDialogTextInk(frame, getDialogTextArea(d.height, d.width, d.anchor))})
+}
+func (d *dialogRevealDetector) Finalize() {
+	d.emit()
+}
+func (d *dialogRevealDetector) Close() {}
@@ -0,0 +1,204 @@
+package process
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"gocv.io/x/gocv"
+)
+
+const testFrameHeight, testFrameWidth = 1080, 1920
+
+func newBlankFrame() gocv.Mat {
+	return gocv.NewMatWithSizeFromScalar(gocv.NewScalar(200, 0, 0, 0), testFrameHeight, testFrameWidth, gocv.MatTypeCV8U)
+}
+
+// pasteMat copies src into frame with its top left corner at p.
+func pasteMat(frame gocv.Mat, src gocv.Mat, p image.Point) {
+	region := frame.Region(image.Rect(p.X, p.Y, p.X+src.Cols(), p.Y+src.Rows()))
+	src.CopyTo(&region)
+	_ = region.Close()
+}
+
+// newDialogTestFrame draws the dialog pointer centered at pointCenter and, when
+// complete is set, the dark text below it that checkFrameDialogStatus looks for.
+func newDialogTestFrame(pointer gocv.Mat, pointCenter image.Point, complete bool) gocv.Mat {
+	frame := newBlankFrame()
+	size := pointer.Cols()
+	pasteMat(frame, pointer, image.Point{X: pointCenter.X - size/2, Y: pointCenter.Y - size/2})
+	if complete {
+		gocv.Rectangle(&frame, image.Rect(pointCenter.X-size, pointCenter.Y+size, pointCenter.X+3*size, pointCenter.Y+3*size),
+			color.RGBA{}, -1)
+	}
+	return frame
+}
+
+// newBannerTestFrame stretches the banner edge template over the area scoreFrameAreaBannerEdge compares.
+func newBannerTestFrame() gocv.Mat {
+	frame := newBlankFrame()
+	area := getBannerArea(testFrameHeight, testFrameWidth)
+	height := float64(area[1] - area[0])
+	cut := image.Rect(
+		int(float64(area[2])-0.1*height), int(float64(area[0])-0.1*height),
+		int(float64(area[3])+0.1*height), int(float64(area[1])+0.1*height),
+	)
+	edge := getResizedAreaEdge(testFrameHeight, testFrameWidth)
+	gocv.Resize(edge, &edge, image.Point{X: cut.Dx(), Y: cut.Dy()}, 0, 0, gocv.InterpolationLinear)
+	pasteMat(frame, edge, cut.Min)
+	_ = edge.Close()
+	return frame
+}
+
+// runDetector feeds d one frame from every generator in order, then finalizes it.
+func runDetector(d Detector, frames []func() gocv.Mat) {
+	d.Init(testFrameHeight, testFrameWidth)
+	for i, next := range frames {
+		frame := next()
+		d.Process(frame, i)
+		_ = frame.Close()
+	}
+	d.Finalize()
+}
+
+func repeatFrame(frames []func() gocv.Mat, n int, next func() gocv.Mat) []func() gocv.Mat {
+	for i := 0; i < n; i++ {
+		frames = append(frames, next)
+	}
+	return frames
+}
+
+func segmentBounds[T any](segments [][]T, frameId func(T) int) [][2]int {
+	var result [][2]int
+	for _, segment := range segments {
+		result = append(result, [2]int{frameId(segment[0]), frameId(segment[len(segment)-1])})
+	}
+	return result
+}
+
+func equalBounds(a, b [][2]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSegmentRecorder(t *testing.T) {
+	var calls [][2]int
+	r := segmentRecorder[int]{kind: "Test", onSegment: func(kind string, index, length int) {
+		if kind != "Test" {
+			t.Errorf("onSegment kind = %q, want %q", kind, "Test")
+		}
+		calls = append(calls, [2]int{index, length})
+	}}
+	r.emit()
+	if r.Segments() != 0 || len(calls) != 0 {
+		t.Fatalf("emit without frames recorded a segment")
+	}
+	r.push(1)
+	r.push(2)
+	r.emit()
+	r.push(5)
+	r.emit()
+	if r.Segments() != 2 {
+		t.Fatalf("Segments() = %d, want 2", r.Segments())
+	}
+	want := [][2]int{{1, 2}, {2, 1}}
+	if len(calls) != len(want) || calls[0] != want[0] || calls[1] != want[1] {
+		t.Errorf("onSegment calls = %v, want %v", calls, want)
+	}
+	if len(r.processing) != 0 {
+		t.Errorf("processing not cleared after emit: %v", r.processing)
+	}
+}
+
+func TestDialogDetectorRuns(t *testing.T) {
+	pointer := getResizedDialogPointer(testFrameHeight, testFrameWidth)
+	defer func() { _ = pointer.Close() }()
+	center := image.Point{X: testFrameWidth / 10, Y: testFrameHeight * 7 / 10}
+	shown := func() gocv.Mat { return newDialogTestFrame(pointer, center, true) }
+
+	var frames []func() gocv.Mat
+	frames = repeatFrame(frames, 3, newBlankFrame)
+	frames = repeatFrame(frames, 5, shown)
+	frames = repeatFrame(frames, 2, newBlankFrame)
+	frames = repeatFrame(frames, 4, shown)
+
+	d := newDialogDetector(DefaultMatchThresholds.Pointer, nil)
+	defer d.Close()
+	runDetector(d, frames)
+
+	got := segmentBounds(d.segments, func(f dialogFrame) int { return f.FrameId })
+	want := [][2]int{{3, 7}, {10, 13}}
+	if !equalBounds(got, want) {
+		t.Fatalf("dialog runs = %v, want %v", got, want)
+	}
+	if len(d.signatures) != len(d.segments) {
+		t.Errorf("got %d name plate signatures for %d runs", len(d.signatures), len(d.segments))
+	}
+	if d.constPointCenter.Eq(image.Point{}) {
+		t.Errorf("pointer position was not recorded")
+	}
+}
+
+func TestDialogDetectorFinalizeDropsIncompleteRun(t *testing.T) {
+	pointer := getResizedDialogPointer(testFrameHeight, testFrameWidth)
+	defer func() { _ = pointer.Close() }()
+	center := image.Point{X: testFrameWidth / 10, Y: testFrameHeight * 7 / 10}
+
+	var frames []func() gocv.Mat
+	frames = repeatFrame(frames, 2, newBlankFrame)
+	frames = repeatFrame(frames, 3, func() gocv.Mat { return newDialogTestFrame(pointer, center, false) })
+
+	d := newDialogDetector(DefaultMatchThresholds.Pointer, nil)
+	defer d.Close()
+	runDetector(d, frames)
+
+	if d.Segments() != 0 {
+		t.Fatalf("dialog runs = %d, want 0 for a pointer without text", d.Segments())
+	}
+	if len(d.processing) != 0 {
+		t.Errorf("Finalize left %d frames pending", len(d.processing))
+	}
+}
+
+func TestBannerDetectorRuns(t *testing.T) {
+	canny, reverse, area := getBannerEdgeTemplates(testFrameHeight, testFrameWidth)
+	blank, banner := newBlankFrame(), newBannerTestFrame()
+	blankScore := float64(scoreFrameAreaBannerEdge(blank, canny, reverse, area))
+	bannerScore := float64(scoreFrameAreaBannerEdge(banner, canny, reverse, area))
+	_ = canny.Close()
+	_ = reverse.Close()
+	_ = blank.Close()
+	_ = banner.Close()
+	if bannerScore <= blankScore {
+		t.Fatalf("banner frame score %.3f is not above blank frame score %.3f", bannerScore, blankScore)
+	}
+
+	var frames []func() gocv.Mat
+	frames = repeatFrame(frames, 2, newBlankFrame)
+	frames = repeatFrame(frames, 3, newBannerTestFrame)
+	frames = repeatFrame(frames, 1, newBlankFrame)
+	frames = repeatFrame(frames, 2, newBannerTestFrame)
+
+	var located []int
+	d := newBannerDetector((blankScore+bannerScore)/2, func(kind string, index, length int) {
+		located = append(located, length)
+	})
+	defer d.Close()
+	runDetector(d, frames)
+
+	got := segmentBounds(d.segments, func(f bannerFrame) int { return f.FrameId })
+	want := [][2]int{{2, 4}, {6, 7}}
+	if !equalBounds(got, want) {
+		t.Fatalf("banner runs = %v, want %v", got, want)
+	}
+	if len(located) != 2 || located[0] != 3 || located[1] != 2 {
+		t.Errorf("onSegment lengths = %v, want [3 2]", located)
+	}
+}
@@ -0,0 +1,211 @@
+package process
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FONT METRICS
+// Only the tables needed to measure text are read: OS/2 and hhea for the font
+// height, hhea and hmtx for advance widths and cmap (formats 4 and 12) for
+// glyph lookup. TrueType, CFF based OpenType fonts and font collections are
+// supported. Like VSFilter and libass, a font size is the height from the
+// Windows ascent to the Windows descent rather than the em size.
+
+type FontMetrics struct {
+	height   int
+	advances []uint16
+	glyphs   map[rune]uint16
+}
+
+type fontReader struct {
+	data []byte
+	err  error
+}
+
+func (r *fontReader) u16(off int) int {
+	if off < 0 || off+2 > len(r.data) {
+		r.err = errors.New("font table out of range")
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(r.data[off:]))
+}
+func (r *fontReader) i16(off int) int {
+	return int(int16(r.u16(off)))
+}
+func (r *fontReader) u32(off int) int {
+	if off < 0 || off+4 > len(r.data) {
+		r.err = errors.New("font table out of range")
+		return 0
+	}
+	return int(binary.BigEndian.Uint32(r.data[off:]))
+}
+
+// tables maps the table tags of the font at index in a collection to their offsets.
+func (r *fontReader) tables(index int) map[string]int {
+	var base = 0
+	if string(r.data[:4]) == "ttcf" {
+		if count := r.u32(8); index >= count {
+			r.err = fmt.Errorf("font collection has %d fonts", count)
+			return nil
+		}
+		base = r.u32(12 + 4*index)
+	}
+	var result = map[string]int{}
+	for i := 0; i < r.u16(base+4); i++ {
+		record := base + 12 + 16*i
+		if record+16 > len(r.data) {
+			r.err = errors.New("font table directory out of range")
+			return nil
+		}
+		result[string(r.data[record:record+4])] = r.u32(record + 8)
+	}
+	return result
+}
+
+func (r *fontReader) cmapFormat4(off int, glyphs map[rune]uint16) {
+	segX2 := r.u16(off + 6)
+	ends, starts := off+14, off+16+segX2
+	deltas, ranges := off+16+2*segX2, off+16+3*segX2
+	for s := 0; s < segX2 && r.err == nil; s += 2 {
+		start, end := r.u16(starts+s), r.u16(ends+s)
+		delta, rangeOffset := r.u16(deltas+s), r.u16(ranges+s)
+		for c := start; c <= end && c != 0xFFFF; c++ {
+			glyph := c + delta
+			if rangeOffset != 0 {
+				glyph = r.u16(ranges + s + rangeOffset + 2*(c-start))
+				if glyph != 0 {
+					glyph += delta
+				}
+			}
+			if glyph&0xFFFF != 0 {
+				glyphs[rune(c)] = uint16(glyph)
+			}
+		}
+	}
+}
+
+func (r *fontReader) cmapFormat12(off int, glyphs map[rune]uint16) {
+	for g := 0; g < r.u32(off+12) && r.err == nil; g++ {
+		group := off + 16 + 12*g
+		start, end, glyph := r.u32(group), r.u32(group+4), r.u32(group+8)
+		if end > 0x10FFFF || end < start {
+			continue
+		}
+		for c := start; c <= end; c++ {
+			glyphs[rune(c)] = uint16(glyph + c - start)
+		}
+	}
+}
+
+// cmap reads the Unicode subtable, preferring the full repertoire format 12.
+func (r *fontReader) cmap(off int) map[rune]uint16 {
+	var best, bestRank = -1, 0
+	for i := 0; i < r.u16(off+2); i++ {
+		record := off + 4 + 8*i
+		platform, encoding, sub := r.u16(record), r.u16(record+2), off+r.u32(record+4)
+		var rank int
+		switch format := r.u16(sub); {
+		case format == 12 && (platform == 0 || (platform == 3 && encoding == 10)):
+			rank = 2
+		case format == 4 && (platform == 0 || (platform == 3 && encoding == 1)):
+			rank = 1
+		}
+		if rank > bestRank {
+			best, bestRank = sub, rank
+		}
+	}
+	var glyphs = map[rune]uint16{}
+	switch bestRank {
+	case 2:
+		r.cmapFormat12(best, glyphs)
+	case 1:
+		r.cmapFormat4(best, glyphs)
+	default:
+		r.err = errors.New("font has no unicode cmap")
+	}
+	return glyphs
+}
+
+// ReadFontMetrics reads a TTF or OTF file, or the font at index of a TTC file.
+func ReadFontMetrics(file string, index int) (*FontMetrics, error) {
+	dat, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(dat) < 12 {
+		return nil, errors.New("not a font file")
+	}
+	r := &fontReader{data: dat}
+	tables := r.tables(index)
+	for _, tag := range []string{"hhea", "hmtx", "cmap"} {
+		if _, ok := tables[tag]; !ok && r.err == nil {
+			r.err = fmt.Errorf("font has no %s table", tag)
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	var result = FontMetrics{}
+	if os2, ok := tables["OS/2"]; ok {
+		result.height = r.u16(os2+74) + r.u16(os2+76)
+	}
+	if result.height == 0 {
+		result.height = r.i16(tables["hhea"]+4) - r.i16(tables["hhea"]+6)
+	}
+	for i := 0; i < r.u16(tables["hhea"]+34); i++ {
+		result.advances = append(result.advances, uint16(r.u16(tables["hmtx"]+4*i)))
+	}
+	result.glyphs = r.cmap(tables["cmap"])
+	if r.err == nil && (result.height <= 0 || len(result.advances) == 0) {
+		r.err = errors.New("font has no metrics")
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &result, nil
+}
+
+// Advance is the advance width of a rune in font sizes; runes missing from the font use the notdef glyph.
+func (f *FontMetrics) Advance(r rune) float64 {
+	glyph := int(f.glyphs[r])
+	if glyph >= len(f.advances) {
+		glyph = len(f.advances) - 1
+	}
+	return float64(f.advances[glyph]) / float64(f.height)
+}
+
+// Width is the rendered width of a line in pixels at a font size, skipping ASS override tags.
+func (f *FontMetrics) Width(line string, fontSize float64) float64 {
+	if f == nil {
+		return estimateTextWidth(line, fontSize)
+	}
+	var width float64
+	for _, r := range assTagReg.ReplaceAllString(line, "") {
+		width += f.Advance(r) * fontSize
+	}
+	return width
+}
+
+var fontMetricsCache = map[string]*FontMetrics{}
+var fontMetricsCacheMux = new(sync.Mutex)
+
+// cachedFontMetrics reads a font once per process; it is nil when no font is given or it cannot be
+// read, and the read error is only returned to the first caller.
+func cachedFontMetrics(file string, index int) (*FontMetrics, error) {
+	if file == "" {
+		return nil, nil
+	}
+	key := fmt.Sprintf("%s#%d", file, index)
+	fontMetricsCacheMux.Lock()
+	defer fontMetricsCacheMux.Unlock()
+	if f, ok := fontMetricsCache[key]; ok {
+		return f, nil
+	}
+	f, err := ReadFontMetrics(file, index)
+	fontMetricsCache[key] = f
+	return f, err
+}
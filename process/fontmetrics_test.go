@@ -0,0 +1,91 @@
+package process
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildTestFont writes a TrueType font with the tables ReadFontMetrics reads:
+// glyph 0 is notdef, glyph 1 "A" and glyph 2 "あ". The OS/2 table is left out
+// when winAscent and winDescent are both 0.
+func buildTestFont(t *testing.T, winAscent, winDescent, ascender, descender int) string {
+	be := binary.BigEndian
+	hhea := make([]byte, 36)
+	be.PutUint16(hhea[4:], uint16(int16(ascender)))
+	be.PutUint16(hhea[6:], uint16(int16(descender)))
+	be.PutUint16(hhea[34:], 3)
+	hmtx := make([]byte, 12)
+	for i, advance := range []uint16{250, 500, 1000} {
+		be.PutUint16(hmtx[4*i:], advance)
+	}
+	cmap := make([]byte, 12+16+2*12)
+	be.PutUint16(cmap[2:], 1)
+	be.PutUint16(cmap[4:], 3)
+	be.PutUint16(cmap[6:], 10)
+	be.PutUint32(cmap[8:], 12)
+	sub := cmap[12:]
+	be.PutUint16(sub, 12)
+	be.PutUint32(sub[4:], uint32(len(sub)))
+	be.PutUint32(sub[12:], 2)
+	for i, group := range [][3]uint32{{'A', 'A', 1}, {'あ', 'あ', 2}} {
+		be.PutUint32(sub[16+12*i:], group[0])
+		be.PutUint32(sub[20+12*i:], group[1])
+		be.PutUint32(sub[24+12*i:], group[2])
+	}
+	tables := []struct {
+		tag  string
+		data []byte
+	}{{"cmap", cmap}, {"hhea", hhea}, {"hmtx", hmtx}}
+	if winAscent != 0 || winDescent != 0 {
+		os2 := make([]byte, 78)
+		be.PutUint16(os2[74:], uint16(winAscent))
+		be.PutUint16(os2[76:], uint16(winDescent))
+		tables = append([]struct {
+			tag  string
+			data []byte
+		}{{"OS/2", os2}}, tables...)
+	}
+	font := make([]byte, 12+16*len(tables))
+	be.PutUint32(font, 0x00010000)
+	be.PutUint16(font[4:], uint16(len(tables)))
+	for i, table := range tables {
+		record := font[12+16*i:]
+		copy(record, table.tag)
+		be.PutUint32(record[8:], uint32(len(font)))
+		be.PutUint32(record[12:], uint32(len(table.data)))
+		font = append(font, table.data...)
+	}
+	file := filepath.Join(t.TempDir(), "test.ttf")
+	if err := os.WriteFile(file, font, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestFontMetricsWidth(t *testing.T) {
+	tests := []struct {
+		winAscent, winDescent, ascender, descender int
+		line                                       string
+		fontSize, want                             float64
+	}{
+		{800, 200, 700, -100, "A", 40, 20},
+		{800, 200, 700, -100, "Aあ", 40, 60},
+		{800, 200, 700, -100, "z", 40, 10},
+		{800, 200, 700, -100, `{\i1}A{\i0}あ`, 40, 60},
+		{1100, 150, 700, -100, "あ", 50, 40},
+		{0, 0, 700, -100, "A", 40, 25},
+		{0, 0, 700, -100, "あ", 40, 50},
+	}
+	for _, tt := range tests {
+		metrics, err := ReadFontMetrics(buildTestFont(t, tt.winAscent, tt.winDescent, tt.ascender, tt.descender), 0)
+		if err != nil {
+			t.Fatalf("ReadFontMetrics: %v", err)
+		}
+		if got := metrics.Width(tt.line, tt.fontSize); got != tt.want {
+			t.Errorf("Width(%q, %v) with win %d/%d and hhea %d/%d = %v, want %v", tt.line, tt.fontSize,
+				tt.winAscent, tt.winDescent, tt.ascender, tt.descender, got, tt.want)
+		}
+	}
+}
@@ -98,7 +98,7 @@ func checkDark(image gocv.Mat, color int) uint8 {
 		return 0
 	}
 }
-func checkFrameContentStart(frame, menuSign gocv.Mat) bool {
+func scoreFrameContentStart(frame, menuSign gocv.Mat) float32 {
 	menuHeight := menuSign.Rows()
 	frameWidth := frame.Cols()
 	cutDown := 3 * menuHeight
@@ -112,10 +112,13 @@ func checkFrameContentStart(frame, menuSign gocv.Mat) bool {
 	_ = res.Close()
 	_ = cut.Close()
 	_ = empty.Close()
-	return maxVal > 0.7
+	return maxVal
+}
+func checkFrameContentStart(frame, menuSign gocv.Mat, threshold float64) bool {
+	return float64(scoreFrameContentStart(frame, menuSign)) > threshold
 }
 
-func checkFrameDialogPointerPosition(frame gocv.Mat, pointer gocv.Mat, lastPointCenter image.Point) image.Point {
+func scoreFrameDialogPointer(frame gocv.Mat, pointer gocv.Mat, lastPointCenter image.Point) (float32, image.Point) {
 	h := frame.Rows()
 	w := frame.Cols()
 	pointerSize := pointer.Cols()
@@ -145,13 +148,17 @@ func checkFrameDialogPointerPosition(frame gocv.Mat, pointer gocv.Mat, lastPoint
 	_ = res.Close()
 	_ = empty.Close()
 
-	if maxVal < 0.8 {
+	return maxVal, image.Point{
+		X: cutLeft + maxLoc.X + int(float64(pointerSize)/2),
+		Y: cutUp + maxLoc.Y + int(float64(pointerSize)/2),
+	}
+}
+func checkFrameDialogPointerPosition(frame gocv.Mat, pointer gocv.Mat, lastPointCenter image.Point, threshold float64) image.Point {
+	score, center := scoreFrameDialogPointer(frame, pointer, lastPointCenter)
+	if float64(score) < threshold {
 		return image.Point{X: 0, Y: 0}
 	} else {
-		return image.Point{
-			X: cutLeft + maxLoc.X + int(float64(pointerSize)/2),
-			Y: cutUp + maxLoc.Y + int(float64(pointerSize)/2),
-		}
+		return center
 	}
 }
 func checkFrameDialogStatus(frame, pointer gocv.Mat, pointCenter image.Point) uint8 {
@@ -182,7 +189,7 @@ func checkFrameDialogStatus(frame, pointer gocv.Mat, pointCenter image.Point) ui
 	}
 	return result
 }
-func checkFrameAreaMarkerPosition(frame, marker gocv.Mat) image.Point {
+func scoreFrameAreaMarker(frame, marker gocv.Mat) (float32, image.Point) {
 	frameHeight := frame.Rows()
 	frameWidth := frame.Cols()
 
@@ -196,13 +203,17 @@ func checkFrameAreaMarkerPosition(frame, marker gocv.Mat) image.Point {
 	_ = cut.Close()
 	_ = empty.Close()
 
-	if maxVal < 0.8 {
+	return maxVal, maxLoc
+}
+func checkFrameAreaMarkerPosition(frame, marker gocv.Mat, threshold float64) image.Point {
+	score, position := scoreFrameAreaMarker(frame, marker)
+	if float64(score) < threshold {
 		return image.Point{}
 	} else {
-		return maxLoc
+		return position
 	}
 }
-func checkFrameAreaBannerEdge(frame, templateCanny, templateReverse gocv.Mat, area [4]int) bool {
+func scoreFrameAreaBannerEdge(frame, templateCanny, templateReverse gocv.Mat, area [4]int) float32 {
 	height := int(math.Abs(float64(area[1] - area[0])))
 	var cutArea = image.Rect(
 		int(float64(area[2])-0.1*float64(height)), int(float64(area[0])-0.1*float64(height)),
@@ -221,7 +232,7 @@ func checkFrameAreaBannerEdge(frame, templateCanny, templateReverse gocv.Mat, ar
 	result := gocv.NewMat()
 	gocv.Canny(mat, &canny, 50, 150)
 	gocv.MatchTemplate(canny, templateCanny, &result, gocv.TmCcoeffNormed, templateReverse)
-	res := result.GetFloatAt(0, 0) > 0.4
+	res := result.GetFloatAt(0, 0)
 
 	_ = mat.Close()
 	_ = canny.Close()
@@ -229,3 +240,184 @@ func checkFrameAreaBannerEdge(frame, templateCanny, templateReverse gocv.Mat, ar
 
 	return res
 }
+func checkFrameAreaBannerEdge(frame, templateCanny, templateReverse gocv.Mat, area [4]int, threshold float64) bool {
+	return float64(scoreFrameAreaBannerEdge(frame, templateCanny, templateReverse, area)) > threshold
+}
+func checkFrameChoiceButtons(frame gocv.Mat) []image.Rectangle {
+	var result []image.Rectangle
+	scale := 160.0 / float64(frame.Cols())
+	small := gocv.NewMat()
+	gocv.Resize(frame, &small, image.Point{}, scale, scale, gocv.InterpolationArea)
+	sh, sw := small.Rows(), small.Cols()
+	brightCount := func(x0, x1, y0, y1 int) int {
+		count := 0
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				if small.GetUCharAt(y, x) > 200 {
+					count += 1
+				}
+			}
+		}
+		return count
+	}
+
+	// The scan ends above the dialog box, whose bright body would read as a button.
+	left, right := sw/5, sw*4/5
+	top, bottom := sh/10, getDialogBoxArea(sh, sw, image.Point{}).Min.Y
+	minHeight := MaxInt([]int{2, int(float64(sh) * 0.03)})
+	maxHeight := int(float64(sh) * 0.15)
+	bandStart := -1
+	for y := top; y <= bottom; y++ {
+		isButtonRow := y < bottom && float64(brightCount(left, right, y, y+1)) >= 0.6*float64(right-left)
+		if isButtonRow && bandStart < 0 {
+			bandStart = y
+		}
+		if isButtonRow || bandStart < 0 {
+			continue
+		}
+		bandEnd := y
+		// A band still open at the bottom is cut off and its height unknown.
+		if bandEnd < bottom && bandEnd-bandStart >= minHeight && bandEnd-bandStart <= maxHeight {
+			runStart, bestStart, bestEnd := -1, 0, 0
+			for x := 0; x <= sw; x++ {
+				isButtonColumn := x < sw &&
+					float64(brightCount(x, x+1, bandStart, bandEnd)) >= 0.6*float64(bandEnd-bandStart)
+				if isButtonColumn && runStart < 0 {
+					runStart = x
+				} else if !isButtonColumn && runStart >= 0 {
+					if x-runStart > bestEnd-bestStart {
+						bestStart, bestEnd = runStart, x
+					}
+					runStart = -1
+				}
+			}
+			if float64(bestEnd-bestStart) >= 0.35*float64(sw) {
+				result = append(result, image.Rect(
+					int(float64(bestStart)/scale), int(float64(bandStart)/scale),
+					int(float64(bestEnd)/scale), int(float64(bandEnd)/scale),
+				))
+			}
+		}
+		bandStart = -1
+	}
+	_ = small.Close()
+	return result
+}
+
+// checkFrameFullScreenText looks for light text on an otherwise black screen and
+// returns the bounding box of the text pixels.
+func checkFrameFullScreenText(frame gocv.Mat) (bool, image.Rectangle) {
+	scale := 160.0 / float64(frame.Cols())
+	small := gocv.NewMat()
+	gocv.Resize(frame, &small, image.Point{}, scale, scale, gocv.InterpolationArea)
+	sh, sw := small.Rows(), small.Cols()
+	var darkCount, brightCount int
+	var bounds image.Rectangle
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			v := small.GetUCharAt(y, x)
+			if v < 40 {
+				darkCount += 1
+			} else if v > 180 {
+				brightCount += 1
+				bounds = bounds.Union(image.Rect(x, y, x+1, y+1))
+			}
+		}
+	}
+	_ = small.Close()
+	total := float64(sh * sw)
+	if float64(darkCount) < 0.85*total || brightCount == 0 || float64(brightCount) > 0.1*total {
+		return false, image.Rectangle{}
+	}
+	return true, image.Rect(
+		int(float64(bounds.Min.X)/scale), int(float64(bounds.Min.Y)/scale),
+		int(float64(bounds.Max.X)/scale), int(float64(bounds.Max.Y)/scale),
+	)
+}
+
+// getDialogBoxArea returns the dialog box rectangle for a known pointer position, or
+// the band at the bottom of the screen where the box appears when it is unknown.
+func getDialogBoxArea(h, w int, pointCenter image.Point) image.Rectangle {
+	if pointCenter.Eq(image.Point{}) {
+		return image.Rect(int(float64(w)*0.1), int(float64(h)*0.68), int(float64(w)*0.9), int(float64(h)*0.97))
+	}
+	_, patternInfo := getFrameData(h, w, pointCenter)
+	return image.Rect(
+		patternInfo.area[0], patternInfo.area[1]+int(55.0*patternInfo.ratio),
+		patternInfo.area[2], patternInfo.area[1]+int(310.0*patternInfo.ratio),
+	).Intersect(image.Rect(0, 0, w, h))
+}
+func checkFrameDialogBoxCoverage(frame gocv.Mat, area image.Rectangle) float64 {
+	if area.Empty() {
+		return 0
+	}
+	cut := frame.Region(area)
+	bright := gocv.NewMat()
+	gocv.Threshold(cut, &bright, 180, 255, gocv.ThresholdBinary)
+	coverage := float64(gocv.CountNonZero(bright)) / float64(area.Dx()*area.Dy())
+	_ = bright.Close()
+	_ = cut.Close()
+	return coverage
+}
+
+// getDialogTextArea is the inner part of the dialog box where the body text is typed.
+func getDialogTextArea(h, w int, pointCenter image.Point) image.Rectangle {
+	box := getDialogBoxArea(h, w, pointCenter)
+	return image.Rect(
+		box.Min.X+box.Dx()/10, box.Min.Y+box.Dy()*3/20,
+		box.Max.X-box.Dx()/10, box.Max.Y-box.Dy()*3/20,
+	)
+}
+
+// checkFrameDialogTextInk returns the share of dark (text) pixels in the text area.
+func checkFrameDialogTextInk(frame gocv.Mat, area image.Rectangle) float64 {
+	if area.Empty() {
+		return 0
+	}
+	cut := frame.Region(area)
+	dark := gocv.NewMat()
+	gocv.Threshold(cut, &dark, 100, 255, gocv.ThresholdBinaryInv)
+	ink := float64(gocv.CountNonZero(dark)) / float64(area.Dx()*area.Dy())
+	_ = dark.Close()
+	_ = cut.Close()
+	return ink
+}
+
+// getDialogCharacterArea is the name plate rectangle drawn by getDialogCharacterMask.
+func getDialogCharacterArea(h, w int, pointCenter image.Point, pointSize int) image.Rectangle {
+	s := getPatternSize(h, w).ratio / getPatternSize(1600, 2560).ratio
+	left := pointCenter.X + pointSize/2
+	return image.Rect(
+		left, pointCenter.Y-int(47*s),
+		left+int(798*s), pointCenter.Y+int(48*s),
+	).Intersect(image.Rect(0, 0, w, h))
+}
+
+// getFrameAreaSignature shrinks an area to a 32x4 grayscale thumbnail used to compare its content across frames.
+func getFrameAreaSignature(frame gocv.Mat, area image.Rectangle) []uint8 {
+	if area.Empty() {
+		return nil
+	}
+	cut := frame.Region(area)
+	small := gocv.NewMat()
+	gocv.Resize(cut, &small, image.Point{X: 32, Y: 4}, 0, 0, gocv.InterpolationArea)
+	var result []uint8
+	for y := 0; y < small.Rows(); y++ {
+		for x := 0; x < small.Cols(); x++ {
+			result = append(result, small.GetUCharAt(y, x))
+		}
+	}
+	_ = small.Close()
+	_ = cut.Close()
+	return result
+}
+func signatureDistance(a, b []uint8) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += math.Abs(float64(a[i]) - float64(b[i]))
+	}
+	return sum / float64(len(a)) / 255.0
+}
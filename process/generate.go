@@ -1,161 +1,236 @@
 package process
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"image"
-	"math"
 	"path"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"gocv.io/x/gocv"
 )
 
+type StaffEntry struct {
+	Role  string   `json:"role"`
+	Names []string `json:"names"`
+}
+
 type StaffItem struct {
-	Recorder       string  `json:"recorder"`
-	Translator     string  `json:"translator"`
-	TranslateProof string  `json:"translate_proof"`
-	SubtitleMaker  string  `json:"subtitle_maker"`
-	SubtitleProof  string  `json:"subtitle_proof"`
-	Compositor     string  `json:"compositor"`
-	Duration       int     `json:"duration"`
-	Position       int     `json:"position"`
-	Suffix         string  `json:"suffix"`
-	Prefix         string  `json:"prefix"`
-	Fade           [2]int  `json:"fade"`
-	FontSize       float64 `json:"fontsize"`
-	FontSizeType   string  `json:"fontsize_type"`
-	MarginLR       int     `json:"margin_lr"`
-	MarginV        int     `json:"margin_v"`
+	Recorder       string            `json:"recorder"`
+	Translator     string            `json:"translator"`
+	TranslateProof string            `json:"translate_proof"`
+	SubtitleMaker  string            `json:"subtitle_maker"`
+	SubtitleProof  string            `json:"subtitle_proof"`
+	Compositor     string            `json:"compositor"`
+	Entries        []StaffEntry      `json:"entries"`
+	Language       string            `json:"language"`
+	RoleLabels     map[string]string `json:"role_labels"`
+	RoleLayout     string            `json:"role_layout"`
+	Template       string            `json:"template"`
+	Anchor         string            `json:"anchor"`
+	Offset         int               `json:"offset"`
+	AvoidDialog    bool              `json:"avoid_dialog"`
+	Duration       int               `json:"duration"`
+	Position       int               `json:"position"`
+	Suffix         string            `json:"suffix"`
+	Prefix         string            `json:"prefix"`
+	Fade           [2]int            `json:"fade"`
+	FontSize       float64           `json:"fontsize"`
+	FontSizeType   string            `json:"fontsize_type"`
+	MarginLR       int               `json:"margin_lr"`
+	MarginV        int               `json:"margin_v"`
 }
 
-func makeStaffBody(item StaffItem) string {
-	type StaffSort struct {
-		Id   string
-		Jobs []string
-	}
-	var result = ""
-	if len(item.Prefix) > 0 {
-		result += item.Prefix + "\n"
-	}
-	var staffs []StaffSort
-	if len(item.Recorder) > 0 {
-		index := -1
-		for i, v := range staffs {
-			if v.Id == item.Recorder {
-				index = i
-				break
-			}
-		}
-		if index == -1 {
-			staffs = append(staffs, StaffSort{Id: item.Recorder, Jobs: []string{"录制"}})
-		} else {
-			staffs[index].Jobs = append(staffs[index].Jobs, "录制")
-		}
+const (
+	StaffLayoutMerged   = "merged"
+	StaffLayoutSeparate = "separate"
+)
+
+const (
+	StaffAnchorAbsolute     = "absolute"
+	StaffAnchorContentStart = "content_start"
+	StaffAnchorFirstDialog  = "first_dialog"
+	StaffAnchorLastDialog   = "last_dialog"
+	StaffAnchorVideoEnd     = "video_end"
+)
+
+// StaffTemplate lays out the credit block. It receives the prefix, the suffix and
+// the credits, each with the role label, the names and the separator of the language.
+const StaffTemplate = `{{if .Prefix}}{{.Prefix}}
+{{end}}{{range .Credits}}{{.Label}}{{$.Separator}}{{.Name}}
+{{end}}{{.Suffix}}`
+
+type staffCredit struct {
+	Label string
+	Roles []string
+	Names []string
+	Name  string
+}
+
+// entries lists the staff by role, converting the legacy role fields when no entries are set.
+func (item StaffItem) entries() []StaffEntry {
+	if len(item.Entries) > 0 {
+		return item.Entries
 	}
-	if len(item.Translator) > 0 {
-		index := -1
-		for i, v := range staffs {
-			if v.Id == item.Translator {
-				index = i
-				break
-			}
-		}
-		if index == -1 {
-			staffs = append(staffs, StaffSort{Id: item.Translator, Jobs: []string{"翻译"}})
-		} else {
-			staffs[index].Jobs = append(staffs[index].Jobs, "翻译")
+	var result []StaffEntry
+	for _, legacy := range []StaffEntry{
+		{"recorder", []string{item.Recorder}}, {"translator", []string{item.Translator}},
+		{"translate_proof", []string{item.TranslateProof}}, {"subtitle_maker", []string{item.SubtitleMaker}},
+		{"subtitle_proof", []string{item.SubtitleProof}}, {"compositor", []string{item.Compositor}},
+	} {
+		if len(legacy.Names[0]) > 0 {
+			result = append(result, legacy)
 		}
 	}
-	if len(item.TranslateProof) > 0 {
-		index := -1
-		for i, v := range staffs {
-			if v.Id == item.TranslateProof {
-				index = i
-				break
-			}
-		}
-		if index == -1 {
-			staffs = append(staffs, StaffSort{Id: item.TranslateProof, Jobs: []string{"校对"}})
-		} else {
-			staffs[index].Jobs = append(staffs[index].Jobs, "校对")
-		}
+	return result
+}
+func (item StaffItem) language() string {
+	if _, ok := StaffRoleLabels[item.Language]; ok {
+		return item.Language
 	}
-	if len(item.SubtitleMaker) > 0 {
-		index := -1
-		for i, v := range staffs {
-			if v.Id == item.SubtitleMaker {
-				index = i
-				break
-			}
-		}
-		if index == -1 {
-			staffs = append(staffs, StaffSort{Id: item.SubtitleMaker, Jobs: []string{"时轴"}})
-		} else {
-			staffs[index].Jobs = append(staffs[index].Jobs, "时轴")
-		}
+	return "zh"
+}
+func (item StaffItem) roleLabel(role string) string {
+	if label, ok := item.RoleLabels[role]; ok {
+		return label
 	}
-	if len(item.SubtitleProof) > 0 {
-		index := -1
-		for i, v := range staffs {
-			if v.Id == item.SubtitleProof {
-				index = i
-				break
-			}
-		}
-		if index == -1 {
-			staffs = append(staffs, StaffSort{Id: item.SubtitleProof, Jobs: []string{"轴校"}})
-		} else {
-			staffs[index].Jobs = append(staffs[index].Jobs, "轴校")
+	if label, ok := StaffRoleLabels[item.language()][role]; ok {
+		return label
+	}
+	return role
+}
+
+// credits groups the roles of every name when merged, or lists every role with its names otherwise.
+func (item StaffItem) credits() []staffCredit {
+	separators := StaffSeparators[item.language()]
+	var result []staffCredit
+	if item.RoleLayout == StaffLayoutSeparate {
+		for _, entry := range item.entries() {
+			result = append(result, staffCredit{Label: item.roleLabel(entry.Role), Roles: []string{entry.Role},
+				Names: entry.Names, Name: strings.Join(entry.Names, separators[2])})
 		}
+		return result
 	}
-	if len(item.Compositor) > 0 {
-		index := -1
-		for i, v := range staffs {
-			if v.Id == item.Compositor {
-				index = i
-				break
+	for _, entry := range item.entries() {
+		for _, name := range entry.Names {
+			index := -1
+			for i, v := range result {
+				if v.Name == name {
+					index = i
+					break
+				}
+			}
+			if index == -1 {
+				result = append(result, staffCredit{Roles: []string{entry.Role}, Names: []string{name}, Name: name})
+			} else {
+				result[index].Roles = append(result[index].Roles, entry.Role)
 			}
 		}
-		if index == -1 {
-			staffs = append(staffs, StaffSort{Id: item.Compositor, Jobs: []string{"压制"}})
-		} else {
-			staffs[index].Jobs = append(staffs[index].Jobs, "压制")
+	}
+	for i, credit := range result {
+		var labels []string
+		for _, role := range credit.Roles {
+			labels = append(labels, item.roleLabel(role))
 		}
+		result[i].Label = strings.Join(labels, separators[1])
 	}
+	return result
+}
 
-	var StaffStrings []string
-	for _, staff := range staffs {
-		StaffStrings = append(StaffStrings, strings.Join(staff.Jobs, "&")+"："+staff.Id)
+func makeStaffBody(item StaffItem) (string, error) {
+	var layout = StaffTemplate
+	if len(item.Template) > 0 {
+		layout = item.Template
 	}
-
-	var StaffString = Strip(strings.Join(StaffStrings, "\n"))
-	if len(StaffString) > 0 {
-		result += StaffString + "\n"
+	tmpl, err := template.New("staff").Parse(layout)
+	if err != nil {
+		return "", err
 	}
-	if len(item.Suffix) > 0 {
-		result += item.Suffix + "\n"
+	var buf bytes.Buffer
+	err = tmpl.Execute(&buf, struct {
+		Prefix, Suffix, Separator string
+		Credits                   []staffCredit
+	}{item.Prefix, item.Suffix, StaffSeparators[item.language()][0], item.credits()})
+	if err != nil {
+		return "", err
 	}
-	result = Strip(result)
+	var result = Strip(buf.String())
 	result = strings.ReplaceAll(result, "\n", "\\N")
 
 	fadeString := fmt.Sprintf("{\\fad(%d,%d)}", item.Fade[0], item.Fade[1])
 	result = fadeString + result
 
-	return result
+	return result, nil
+}
+
+// staffSpan places a credit relative to its anchor in milliseconds. Credits anchored
+// to the video end finish there, every other credit starts there; the offset shifts both.
+func (t *Task) staffSpan(item StaffItem, matched matchResult, tl timeline) (start int, end int, err error) {
+	var duration = item.Duration * 1000
+	var anchor int
+	switch item.Anchor {
+	case "", StaffAnchorAbsolute:
+		anchor = 0
+	case StaffAnchorContentStart:
+		anchor = tl.At(matched.contentStartFrame)
+	case StaffAnchorFirstDialog, StaffAnchorLastDialog:
+		if len(matched.dialogFrameSet) == 0 {
+			return 0, 0, fmt.Errorf("no dialog detected for anchor %s", item.Anchor)
+		}
+		if item.Anchor == StaffAnchorFirstDialog {
+			anchor = tl.At(matched.dialogFrameSet[0][0].FrameId)
+		} else {
+			last := matched.dialogFrameSet[len(matched.dialogFrameSet)-1]
+			anchor = tl.At(last[len(last)-1].FrameId + 1)
+		}
+	case StaffAnchorVideoEnd:
+		anchor = tl.At(matched.endFrame) - duration
+	default:
+		return 0, 0, fmt.Errorf("unknown anchor %s", item.Anchor)
+	}
+	start = MaxInt([]int{0, anchor + item.Offset})
+	return start, start + duration, nil
 }
-func makeStaffEvent(item StaffItem, dialogFontSize int, fontName string) (SubtitleEventItem, SubtitleStyleItem) {
-	var body = makeStaffBody(item)
+
+// overlapsDialog reports whether a span shares time with a shown dialog box, or with
+// a dialog when the box was not detected.
+func overlapsDialog(matched matchResult, tl timeline, start int, end int) bool {
+	var spans [][2]int
+	for _, run := range matched.dialogBoxSet {
+		spans = append(spans, [2]int{run[0].FrameId, run[len(run)-1].FrameId})
+	}
+	if len(spans) == 0 {
+		for _, run := range matched.dialogFrameSet {
+			spans = append(spans, [2]int{run[0].FrameId, run[len(run)-1].FrameId})
+		}
+	}
+	for _, span := range spans {
+		if tl.At(span[0]) < end && tl.At(span[1]+1) > start {
+			return true
+		}
+	}
+	return false
+}
+
+func makeStaffEvent(item StaffItem, start int, end int, avoidDialog bool, dialogFontSize int, fontName string) (
+	SubtitleEventItem, SubtitleStyleItem, error) {
+	body, err := makeStaffBody(item)
+	if err != nil {
+		return SubtitleEventItem{}, SubtitleStyleItem{}, err
+	}
 	var styleName = fmt.Sprintf("Staff-%s", Md5Len3(body))
 	var e = SubtitleEventItem{
 		Type:    "Dialogue",
 		Layer:   1,
-		Start:   "0:00:00.00",
-		End:     fmt.Sprintf("0:00:%02d:%02d", item.Duration/60, item.Duration%60),
+		Start:   Timecode(start),
+		End:     Timecode(end),
 		Style:   styleName,
 		Name:    "staff",
 		MarginL: 0,
@@ -175,10 +250,15 @@ func makeStaffEvent(item StaffItem, dialogFontSize int, fontName string) (Subtit
 	s.Fontsize = int(fs)
 	s.FontName = fontName
 	s.Alignment = item.Position
+	if avoidDialog && item.Position >= 1 && item.Position <= 3 {
+		s.Name = styleName + "-Top"
+		e.Style = s.Name
+		s.Alignment = item.Position + 6
+	}
 	s.MarginL = item.MarginLR
 	s.MarginR = item.MarginLR
 	s.MarginV = item.MarginV
-	return e, s
+	return e, s, nil
 }
 
 // MATCH
@@ -187,8 +267,8 @@ type frameDialogProcessResult struct {
 	pointCenter image.Point
 }
 
-func matchFrameDialog(frame, pointer gocv.Mat, lastPointPosition image.Point) frameDialogProcessResult {
-	center := checkFrameDialogPointerPosition(frame, pointer, lastPointPosition)
+func matchFrameDialog(frame, pointer gocv.Mat, lastPointPosition image.Point, threshold float64) frameDialogProcessResult {
+	center := checkFrameDialogPointerPosition(frame, pointer, lastPointPosition, threshold)
 	status := checkFrameDialogStatus(frame, pointer, center)
 	result := frameDialogProcessResult{
 		status:      status,
@@ -196,14 +276,14 @@ func matchFrameDialog(frame, pointer gocv.Mat, lastPointPosition image.Point) fr
 	}
 	return result
 }
-func matchFrameBanner(frame, bannerCanny, bannerReverse gocv.Mat, bannerMaskArea [4]int) bool {
-	return checkFrameAreaBannerEdge(frame, bannerCanny, bannerReverse, bannerMaskArea)
+func matchFrameBanner(frame, bannerCanny, bannerReverse gocv.Mat, bannerMaskArea [4]int, threshold float64) bool {
+	return checkFrameAreaBannerEdge(frame, bannerCanny, bannerReverse, bannerMaskArea, threshold)
 }
-func matchFrameMarker(frame, marker gocv.Mat) image.Point {
-	return checkFrameAreaMarkerPosition(frame, marker)
+func matchFrameMarker(frame, marker gocv.Mat, threshold float64) image.Point {
+	return checkFrameAreaMarkerPosition(frame, marker, threshold)
 }
-func matchCheckStart(frame, menuSign gocv.Mat) bool {
-	return checkFrameContentStart(frame, menuSign)
+func matchCheckStart(frame, menuSign gocv.Mat, threshold float64) bool {
+	return checkFrameContentStart(frame, menuSign, threshold)
 }
 
 // DIALOG
@@ -212,121 +292,173 @@ type dialogFrame struct {
 	PointCenter image.Point
 }
 
-func dialogBodyTyper(body string, charInterval [2]int) string {
-	returnChar := []string{"\n", "\\n", "\\N"}
-	bodyCopy := body
-	for _, s := range returnChar {
-		bodyCopy = strings.ReplaceAll(bodyCopy, s, "\n")
-	}
-	t := ""
-	var bodyArr []string
-	for _, v := range bodyCopy {
-		c := string(v)
-		if c == "." {
-			if t == "" || t == "." || t == ".." {
-				t = strings.Join([]string{t, c}, "")
-				if t == "..." {
-					bodyArr = append(bodyArr, t)
-					t = ""
-				}
-			} else {
-				bodyArr = append(bodyArr, c)
-			}
-		} else {
-			bodyArr = append(bodyArr, c)
-		}
-	}
-
-	res := ""
-	nextStart := 0
-	fadeTime := charInterval[0]
-	charTime := charInterval[1]
-	for _, v := range bodyArr {
-		c := v
-		r := ""
-		var start int
-		if fadeTime > 0 && charTime > 0 {
-			start = nextStart
-			end := start + fadeTime
-			if c == "\n" {
-				start += 300
-			}
-			r += fmt.Sprintf("{\\alphaFF\\t(%d,%d,1,\\alpha0)}", start, end)
+type dialogBoxFrame struct {
+	FrameId  int
+	Coverage float64
+}
+
+type dialogBoundary struct {
+	FrameId   int
+	Reason    string
+	Signature []uint8
+}
+
+const dialogBoundaryMinFrames = 3
+
+// dialogRunCuts finds where a dialog run is cut by the boundaries from boundaries[b]
+// on. It returns the frame index every resulting line starts at, the boundary that
+// started it (-1 for the start of the run) and the first boundary past the run.
+func dialogRunCuts(frames []dialogFrame, boundaries [][]dialogBoundary, b int) (starts []int, causes []int, next int) {
+	starts, causes = []int{0}, []int{-1}
+	for ; b < len(boundaries); b++ {
+		boundary := boundaries[b][0]
+		if boundary.FrameId >= frames[len(frames)-1].FrameId-dialogBoundaryMinFrames {
+			break
 		}
-		if c == "\n" {
-			r += "\\N"
-		} else {
-			r += c
+		start := starts[len(starts)-1]
+		cut := start
+		for cut < len(frames) && frames[cut].FrameId < boundary.FrameId {
+			cut += 1
 		}
-		res += r
-		nextStart = start + charTime
+		if cut-start < dialogBoundaryMinFrames {
+			continue
+		}
+		starts, causes = append(starts, cut), append(causes, b)
 	}
-	return res
+	return starts, causes, b
 }
-func dialogBodyTyperCalculator(body string, frameCount int, frameTimeMs float64, charInterval [2]int) string {
-	returnChar := []string{"\n", "\\n", "\\N"}
-	bodyCopy := body
-	for _, s := range returnChar {
-		bodyCopy = strings.ReplaceAll(bodyCopy, s, "\n")
-	}
-	t := ""
-	var bodyArr []string
-	for _, v := range bodyCopy {
-		c := string(v)
-		if c == "." {
-			if t == "" || t == "." || t == ".." {
-				t = strings.Join([]string{t, c}, "")
-				if t == "..." {
-					bodyArr = append(bodyArr, t)
-					t = ""
-				}
-			} else {
-				bodyArr = append(bodyArr, c)
+
+// splitDialogRuns cuts the dialog runs at the name plate and text boundaries found
+// inside them, and logs every resulting run with how its start was determined.
+// The numbers logged here are the ones corrections refer to.
+func (t *Task) splitDialogRuns(runs [][]dialogFrame, signatures [][]uint8, boundaries [][]dialogBoundary) (
+	[][]dialogFrame, [][]uint8) {
+	var resultRuns [][]dialogFrame
+	var resultSignatures [][]uint8
+	var b = 0
+	for i, frames := range runs {
+		var signature []uint8
+		if i < len(signatures) {
+			signature = signatures[i]
+		}
+		var reason = "Pointer Lost"
+		if i == 0 {
+			reason = "Pointer Shown"
+		}
+		var starts, causes []int
+		starts, causes, b = dialogRunCuts(frames, boundaries, b)
+		for j, start := range starts {
+			var end = len(frames)
+			if j+1 < len(starts) {
+				end = starts[j+1]
 			}
-		} else {
-			bodyArr = append(bodyArr, c)
-		}
-	}
-
-	nowTime := int(frameTimeMs * float64(frameCount) * 1000.0)
-	transAlphaString := "{\\alpha&HFF&}"
-	isTransNow := false
-	charTimeNow := 0
-	fadeTime := charInterval[0]
-	charTime := charInterval[1]
-	res := ""
-	for _, v := range bodyArr {
-		c := v
-		addTrans := ""
-		charTimeNow += charTime
-		if c == "\n" {
-			charTimeNow += 300
-		}
-		if charTimeNow < nowTime && nowTime < charTimeNow+fadeTime {
-			la := (nowTime - charTimeNow) / fadeTime * 255
-			addTrans = fmt.Sprintf("{\\alpha%d}", la)
-		} else if charTimeNow > nowTime {
-			if !isTransNow {
-				addTrans = transAlphaString
-				isTransNow = true
+			if causes[j] >= 0 {
+				signature, reason = boundaries[causes[j]][0].Signature, boundaries[causes[j]][0].Reason
 			}
+			resultRuns = append(resultRuns, frames[start:end])
+			resultSignatures = append(resultSignatures, signature)
+			go t.Log(Log{Type: "string",
+				Data: fmt.Sprintf("[Processing] Locate %d Frames for Dialog No.%d", end-start, len(resultRuns))})
+			go t.Log(Log{Type: "string",
+				Data: fmt.Sprintf("[Processing] Dialog No.%d Starts at Frame %d: %s", len(resultRuns), frames[start].FrameId, reason)})
 		}
-		if c == "\n" {
-			c = "\\N"
+	}
+	return resultRuns, resultSignatures
+}
+
+// dialogLineCounter counts the dialog lines finished during the scan as
+// splitDialogRuns will cut them, so effects are gated on story line indexes.
+// Completed runs are counted once; only the open run is cut again every frame.
+type dialogLineCounter struct {
+	runs     int
+	lines    int
+	boundary int
+}
+
+func (c *dialogLineCounter) count(runs [][]dialogFrame, open []dialogFrame, boundaries [][]dialogBoundary) int {
+	for ; c.runs < len(runs); c.runs++ {
+		var starts []int
+		starts, _, c.boundary = dialogRunCuts(runs[c.runs], boundaries, c.boundary)
+		c.lines += len(starts)
+	}
+	if len(open) == 0 {
+		return c.lines
+	}
+	starts, _, _ := dialogRunCuts(open, boundaries, c.boundary)
+	return c.lines + len(starts) - 1
+}
+
+// dialogPhase holds the frame ranges [start, end) of the dialog box open and close
+// animations around a dialog. Empty ranges mean the box stays open.
+type dialogPhase struct {
+	OpenStart  int
+	OpenEnd    int
+	CloseStart int
+	CloseEnd   int
+}
+
+func (p dialogPhase) opens() bool  { return p.OpenEnd > p.OpenStart }
+func (p dialogPhase) closes() bool { return p.CloseEnd > p.CloseStart }
+
+func computeDialogPhases(boxFrameSet [][]dialogBoxFrame, dialogFrameSet [][]dialogFrame) []dialogPhase {
+	var phases = make([]dialogPhase, len(dialogFrameSet))
+	var lastBox = -1
+	for i, frames := range dialogFrameSet {
+		if len(frames) == 0 {
+			continue
 		}
-		if fadeTime > 0 && charTime > 0 {
-			res += addTrans + c
-		} else {
-			res += c
+		start, end := frames[0].FrameId, frames[len(frames)-1].FrameId
+		box := -1
+		for j, boxFrames := range boxFrameSet {
+			if boxFrames[0].FrameId <= start && boxFrames[len(boxFrames)-1].FrameId >= end {
+				box = j
+				break
+			}
+		}
+		if box < 0 {
+			continue
+		}
+		boxFrames := boxFrameSet[box]
+		var coverages []float64
+		for _, f := range boxFrames {
+			coverages = append(coverages, f.Coverage)
+		}
+		sort.Float64s(coverages)
+		level := coverages[len(coverages)/2] * 0.95
+
+		if box != lastBox {
+			phases[i].OpenStart = boxFrames[0].FrameId
+			phases[i].OpenEnd = boxFrames[0].FrameId
+			for _, f := range boxFrames {
+				if f.Coverage >= level || f.FrameId >= start {
+					phases[i].OpenEnd = f.FrameId
+					break
+				}
+			}
+		}
+		lastBox = box
+		boxEnd := boxFrames[len(boxFrames)-1].FrameId + 1
+		if i+1 == len(dialogFrameSet) || len(dialogFrameSet[i+1]) == 0 || dialogFrameSet[i+1][0].FrameId >= boxEnd {
+			phases[i].CloseStart = boxEnd
+			phases[i].CloseEnd = boxEnd
+			for k := len(boxFrames) - 1; k >= 0; k-- {
+				if boxFrames[k].Coverage >= level || boxFrames[k].FrameId <= end {
+					phases[i].CloseStart = boxFrames[k].FrameId + 1
+					break
+				}
+			}
 		}
-
 	}
-	return res
+	return phases
+}
+
+func dialogFontSize(pointSize int) int {
+	return int(float64(pointSize) * (83.0 / 56.0))
 }
 func dialogMakeStyle(config TaskConfig, pointCenter image.Point, pointSize int) []SubtitleStyleItem {
 	styles := GetDialogStyle()
 	for i, style := range styles {
-		style.Fontsize = int(float64(pointSize) * (83.0 / 56.0))
+		style.Fontsize = dialogFontSize(pointSize)
 		if len(config.Font) > 0 {
 			style.FontName = config.Font
 		}
@@ -338,24 +470,59 @@ func dialogMakeStyle(config TaskConfig, pointCenter image.Point, pointSize int)
 		}
 		styles[i] = style
 	}
+	choiceStyle := ChoiceStyleFormat
+	choiceStyle.Fontsize = int(float64(pointSize) * (70.0 / 56.0))
+	if len(config.Font) > 0 {
+		choiceStyle.FontName = config.Font
+	}
+	fullScreenStyle := FullScreenTextStyleFormat
+	fullScreenStyle.Fontsize = int(float64(pointSize) * (75.0 / 56.0))
+	if len(config.Font) > 0 {
+		fullScreenStyle.FontName = config.Font
+	}
+	styles = append(styles, choiceStyle, fullScreenStyle)
+	if config.Bilingual != "" {
+		styles = append(styles, makeSecondaryStyles(config, styles)...)
+	}
 	return styles
 }
+
+// styleFontSize is the font size of the named style, or fallback when there is no such style.
+func styleFontSize(styles []SubtitleStyleItem, name string, fallback int) int {
+	for _, style := range styles {
+		if style.Name == name {
+			return style.Fontsize
+		}
+	}
+	return fallback
+}
+
 func dialogMakeEvent(
-	dialogInfo StoryEvent, pointSize, h, w int, frameTime float64, lastDialogLastFrame dialogFrame, dialogFrames []dialogFrame,
-	lastDialogLastEvent SubtitleEventItem, dialogIsMaskStart bool, config TaskConfig,
+	dialogInfo StoryEvent, pointSize, h, w int, tl timeline, lastDialogLastFrame dialogFrame, dialogFrames []dialogFrame,
+	lastDialogLastEvent SubtitleEventItem, dialogIsMaskStart bool, phase dialogPhase, config TaskConfig,
+	styles []SubtitleStyleItem,
 ) ([]SubtitleEventItem, []SubtitleEventItem, []SubtitleEventItem, []SubtitleEventItem) {
 	startFrame := dialogFrames[0]
 	endFrame := dialogFrames[len(dialogFrames)-1]
 
-	var displayName = dialogInfo.Content().Character
-	var dialogBody = dialogInfo.Content().Body
+	primary, secondary := config.bilingualContent(dialogInfo)
+	var displayName = primary.Character
+	var dialogBody = primary.Body
 	var styleName = "関連人物"
 	if len(dialogBody) > 0 {
-		s := CIDStyle[dialogInfo.CharacterId]
+		s := Characters.Style(dialogInfo.character2DId())
 		if s != "" {
 			styleName = s
 		}
 	}
+	if config.Wrap && len(dialogFrames) > 0 {
+		metrics, _ := cachedFontMetrics(config.FontFile, config.FontIndex)
+		width := dialogWrapWidth(h, w, dialogFrames[0].PointCenter, pointSize)
+		fontSize := float64(styleFontSize(styles, styleName, dialogFontSize(pointSize)))
+		secondarySize := float64(styleFontSize(styles, DialogSecondaryStyle, int(fontSize*config.secondaryScale())))
+		dialogBody = wrapDialogBody(dialogBody, width, fontSize, metrics, config.WrapShrink)
+		secondary = wrapDialogBody(secondary, width, secondarySize, metrics, 0)
+	}
 
 	var framePoints []image.Point
 	for _, frame := range dialogFrames {
@@ -365,14 +532,20 @@ func dialogMakeEvent(
 	jitter := CheckMaxDistance(framePoints) > 3
 	if !jitter {
 		pointCenterConst := dialogFrames[0].PointCenter
-		startTime := MsToString(int(frameTime * float64(startFrame.FrameId)))
-		endTime := MsToString(int(frameTime * float64(endFrame.FrameId)))
+		startTime := Timecode(tl.At(startFrame.FrameId))
+		endTime := Timecode(tl.At(endFrame.FrameId))
 		if (!dialogIsMaskStart) && (lastDialogLastFrame.FrameId != 0) {
 			startTime = lastDialogLastEvent.End
 		}
+		var fadeOut = ""
+		if phase.closes() {
+			endTime = Timecode(tl.At(phase.CloseEnd))
+			fadeOut = fmt.Sprintf("{\\fad(0,%d)}", tl.Between(phase.CloseStart, phase.CloseEnd))
+		}
 		bodyEvent := SubtitleEventItem{
 			Type: "Dialogue", Layer: 2, Start: startTime, End: endTime, Style: styleName, Name: displayName,
-			MarginL: 0, MarginR: 0, MarginV: 0, Effect: "", Text: dialogBodyTyper(dialogBody, config.TyperInterval),
+			MarginL: 0, MarginR: 0, MarginV: 0, Effect: "", Text: fadeOut + dialogBodyTyper(dialogBody, config) +
+				secondaryLine(dialogBodyTyper(secondary, config.secondaryConfig()), DialogSecondaryStyle),
 		}
 		maskEvent := bodyEvent
 		_, patternInfo := getFrameData(h, w, pointCenterConst)
@@ -380,14 +553,26 @@ func dialogMakeEvent(
 		maskEvent.Text = maskString
 		maskEvent.Style = "screen"
 		maskEvent.Layer = 1
+		var fadeInMs, fadeOutMs int
 		if dialogIsMaskStart {
-			maskEvent.Text = "{\\fad(100,0)}" + maskEvent.Text
-			maskEvent.Start = MsToString(int(frameTime * float64(MaxInt([]int{0, startFrame.FrameId - 6}))))
+			if phase.opens() {
+				fadeInMs = tl.Between(phase.OpenStart, phase.OpenEnd)
+				maskEvent.Start = Timecode(tl.At(phase.OpenStart))
+			} else {
+				fadeInMs = 100
+				maskEvent.Start = Timecode(tl.At(MaxInt([]int{0, startFrame.FrameId - 6})))
+			}
+		}
+		if phase.closes() {
+			fadeOutMs = tl.Between(phase.CloseStart, phase.CloseEnd)
+		}
+		if fadeInMs > 0 || fadeOutMs > 0 {
+			maskEvent.Text = fmt.Sprintf("{\\fad(%d,%d)}", fadeInMs, fadeOutMs) + maskEvent.Text
 		}
 		// Character
 		charaMaskString := getDialogCharacterMask(h, w, pointCenterConst, pointSize)
 		charaMaskEvent := bodyEvent
-		charaMaskEvent.Text = charaMaskString
+		charaMaskEvent.Text = fadeOut + charaMaskString
 		charaMaskEvent.Style = "screen"
 		charaMaskEvent.Layer = 1
 		if config.VideoOnly {
@@ -397,7 +582,7 @@ func dialogMakeEvent(
 		charaBodyEvent := charaMaskEvent
 		_, cmo := SplitArr(Str2IntArr(charaMaskString))
 		charaOffset := int(float64(MinInt(cmo)) * 1.1)
-		charaBodyEvent.Text = fmt.Sprintf("{\\pos(%d,%d)\\an4}",
+		charaBodyEvent.Text = fadeOut + fmt.Sprintf("{\\pos(%d,%d)\\an4}",
 			pointCenterConst.X+charaOffset, pointCenterConst.Y) + displayName
 		charaBodyEvent.Style = "character"
 		charaBodyEvent.Layer = 2
@@ -408,12 +593,20 @@ func dialogMakeEvent(
 		for i, frame := range dialogFrames {
 			move := fmt.Sprintf("{\\an7\\pos(%d,%d)}",
 				frame.PointCenter.X-pointSize/2, int(float64(frame.PointCenter.Y)+1.25*float64(pointSize)))
-			body := dialogBodyTyperCalculator(dialogBody, i, frameTime, config.TyperInterval)
+			body := dialogBodyTyperCalculator(dialogBody, i, tl.frameTimeMs, config)
+			if config.TyperMode == TyperModeNone {
+				body = dialogBodyTyper(dialogBody, config)
+			}
+			secondaryBody := dialogBodyTyper(secondary, config.secondaryConfig())
+			if config.secondaryConfig().TyperMode != TyperModeNone {
+				secondaryBody = dialogBodyTyperCalculator(secondary, i, tl.frameTimeMs, config.secondaryConfig())
+			}
+			body += secondaryLine(secondaryBody, DialogSecondaryStyle)
 			frameBody := move + body
 			bodyEvent := SubtitleEventItem{
 				Type: "Dialogue", Layer: 1, MarginL: 0, MarginR: 0, MarginV: 0, Effect: "",
-				Start: MsToString(int(frameTime * float64(frame.FrameId))),
-				End:   MsToString(int(frameTime * float64(frame.FrameId+1))),
+				Start: Timecode(tl.At(frame.FrameId)),
+				End:   Timecode(tl.At(frame.FrameId + 1)),
 				Style: styleName, Name: displayName, Text: frameBody,
 			}
 			if len(bodyEvents) > 0 {
@@ -459,25 +652,25 @@ func dialogMakeEvent(
 
 		b := bodyEvents[len(bodyEvents)-1]
 		b.Type = "Comment"
-		b.Start = MsToString(int(frameTime * float64(startFrame.FrameId)))
-		b.Text = dialogBody
+		b.Start = Timecode(tl.At(startFrame.FrameId))
+		b.Text = dialogBody + secondaryLine(secondary, DialogSecondaryStyle)
 		bodyEvents = append(bodyEvents, b)
 
 		m := maskEvents[len(maskEvents)-1]
 		m.Type = "Comment"
-		m.Start = MsToString(int(frameTime * float64(startFrame.FrameId)))
+		m.Start = Timecode(tl.At(startFrame.FrameId))
 		m.Text = getDialogMask(patternInfo, [2]int{})
 		maskEvents = append(maskEvents, m)
 
 		cb := charaBodyEvents[len(charaBodyEvents)-1]
 		cb.Type = "Comment"
-		cb.Start = MsToString(int(frameTime * float64(startFrame.FrameId)))
+		cb.Start = Timecode(tl.At(startFrame.FrameId))
 		cb.Text = displayName
 		charaBodyEvents = append(charaBodyEvents, cb)
 
 		cm := charaMaskEvents[len(charaMaskEvents)-1]
 		cm.Type = "Comment"
-		cm.Start = MsToString(int(frameTime * float64(startFrame.FrameId)))
+		cm.Start = Timecode(tl.At(startFrame.FrameId))
 		cb.Text = getDialogCharacterMask(h, w, startFrame.PointCenter, pointSize)
 		return charaMaskEvents, charaBodyEvents, maskEvents, bodyEvents
 	}
@@ -488,15 +681,15 @@ type bannerFrame struct {
 	FrameId int
 }
 
-func bannerMakeEvent(bannerInfo StoryEvent, areaMask string, frameTime float64, frames []bannerFrame) []SubtitleEventItem {
-	var fadeFrame = 100.0 / frameTime
+func bannerMakeEvent(bannerInfo StoryEvent, areaMask string, tl timeline, frames []bannerFrame, config TaskConfig) []SubtitleEventItem {
 	var mask = SubtitleEventItem{
 		Type: "Dialogue", Style: "address", Layer: 1, Name: "", MarginL: 0, MarginR: 0, MarginV: 0, Effect: "",
-		Start: MsToString(int(frameTime * float64(MaxInt([]int{int(float64(frames[0].FrameId) - fadeFrame), 0})))),
-		End:   MsToString(int(frameTime * (float64(frames[len(frames)-1].FrameId) + fadeFrame))),
+		Start: Timecode(tl.Before(frames[0].FrameId, 100)),
+		End:   Timecode(tl.After(frames[len(frames)-1].FrameId, 100)),
 		Text:  "{\\fad(100,100)}" + areaMask}
 	body := mask
-	body.Text = "{\\fad(100,100)}" + bannerInfo.Content().Body
+	primary, secondary := config.bilingualContent(bannerInfo)
+	body.Text = "{\\fad(100,100)}" + primary.Body + secondaryLine(secondary, AddressSecondaryStyle)
 	body.Layer = 2
 	var events = []SubtitleEventItem{mask, body}
 	return events
@@ -508,11 +701,16 @@ type markerFrame struct {
 	FrameId  int
 }
 
-func markerMakeEvent(markerInfo StoryEvent, h, w int, frameTime float64, frames []markerFrame) []SubtitleEventItem {
+func markerMakeEvent(markerInfo StoryEvent, h, w int, tl timeline, frames []markerFrame, config TaskConfig) []SubtitleEventItem {
 	var maskEvents []SubtitleEventItem
 	var bodyEvents []SubtitleEventItem
-	markerBody := markerInfo.Content().Body
+	primary, secondary := config.bilingualContent(markerInfo)
 	maskString, maskSize := getAreaMarkerMask(h, w)
+	markerBody := primary.Body
+	if len(secondary) > 0 {
+		markerBody += fmt.Sprintf("\\N{\\r%s\\fs%d}%s",
+			AddressSecondaryStyle, int(float64(maskSize[0])*config.secondaryScale()), secondary)
+	}
 
 	for _, frame := range frames {
 		rightPosition := image.Point{X: frame.Position.X, Y: int(float64(frame.Position.Y) * 7 / 6)}
@@ -522,8 +720,8 @@ func markerMakeEvent(markerInfo StoryEvent, h, w int, frameTime float64, frames
 			maskSize[0], rightPosition.X-(maskSize[1]*9/10), rightPosition.Y)
 		bodyEvent := SubtitleEventItem{
 			Type: "Dialogue", Style: "address", Layer: 2, Name: "",
-			Start:   MsToString(int(float64(frame.FrameId) * frameTime)),
-			End:     MsToString(int(float64(frame.FrameId+1) * frameTime)),
+			Start:   Timecode(tl.At(frame.FrameId)),
+			End:     Timecode(tl.At(frame.FrameId + 1)),
 			MarginL: 0, MarginR: 0, MarginV: 0, Effect: "",
 			Text: bodyPosition + markerBody}
 		if len(bodyEvents) > 0 && bodyEvents[len(bodyEvents)-1].Text == bodyEvent.Text {
@@ -551,28 +749,120 @@ func markerMakeEvent(markerInfo StoryEvent, h, w int, frameTime float64, frames
 	return append(maskEvents, bodyEvents...)
 }
 
+// CHOICE
+type choiceFrame struct {
+	FrameId int
+	Buttons []image.Rectangle
+}
+
+func choiceMakeEvent(options StoryEventSet, tl timeline, frames []choiceFrame) []SubtitleEventItem {
+	var maskEvents []SubtitleEventItem
+	var bodyEvents []SubtitleEventItem
+	var buttons []image.Rectangle
+	for _, frame := range frames {
+		if len(frame.Buttons) > len(buttons) {
+			buttons = frame.Buttons
+		}
+	}
+	startTime := Timecode(tl.Before(frames[0].FrameId, 100))
+	endTime := Timecode(tl.After(frames[len(frames)-1].FrameId, 100))
+	for i, button := range buttons {
+		var body string
+		if i < options.Count() {
+			body = options[i].Content().Body
+		}
+		inset := button.Dy() / 10
+		round := button.Dy() / 2
+		mask := fmt.Sprintf("{\\an7\\p1\\c&HFFFFFF&\\pos(0,0)\\fad(100,100)}m %d %d l %d %d l %d %d l %d %d",
+			button.Min.X+round, button.Min.Y+inset, button.Max.X-round, button.Min.Y+inset,
+			button.Max.X-round, button.Max.Y-inset, button.Min.X+round, button.Max.Y-inset)
+		maskEvent := SubtitleEventItem{
+			Type: "Dialogue", Style: "choice", Layer: 1, Name: "", MarginL: 0, MarginR: 0, MarginV: 0, Effect: "",
+			Start: startTime, End: endTime, Text: mask,
+		}
+		bodyEvent := maskEvent
+		bodyEvent.Layer = 2
+		bodyEvent.Text = fmt.Sprintf("{\\fad(100,100)\\an5\\pos(%d,%d)}",
+			(button.Min.X+button.Max.X)/2, (button.Min.Y+button.Max.Y)/2) + body
+		maskEvents = append(maskEvents, maskEvent)
+		bodyEvents = append(bodyEvents, bodyEvent)
+	}
+	return append(maskEvents, bodyEvents...)
+}
+
+// FULL SCREEN TEXT
+type fullScreenTextFrame struct {
+	FrameId int
+	Bounds  image.Rectangle
+}
+
+func fullScreenTextMakeEvent(textInfo StoryEvent, h, w int, tl timeline, frames []fullScreenTextFrame) []SubtitleEventItem {
+	var bounds = frames[0].Bounds
+	for _, frame := range frames {
+		bounds = bounds.Union(frame.Bounds)
+	}
+	bounds = bounds.Inset(-h / 20).Intersect(image.Rect(0, 0, w, h))
+	var mask = SubtitleEventItem{
+		Type: "Dialogue", Style: "fullscreen", Layer: 1, Name: "", MarginL: 0, MarginR: 0, MarginV: 0, Effect: "",
+		Start: Timecode(tl.Before(frames[0].FrameId, 100)),
+		End:   Timecode(tl.After(frames[len(frames)-1].FrameId, 100)),
+		Text: fmt.Sprintf("{\\an7\\p1\\c&H000000&\\pos(0,0)\\fad(100,100)}m %d %d l %d %d l %d %d l %d %d",
+			bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Min.Y, bounds.Max.X, bounds.Max.Y, bounds.Min.X, bounds.Max.Y),
+	}
+	body := mask
+	body.Text = fmt.Sprintf("{\\fad(100,100)\\an5\\pos(%d,%d)}", w/2, h/2) + textInfo.Content().Body
+	body.Layer = 2
+	return []SubtitleEventItem{mask, body}
+}
+
 // TASK
 
 type TaskConfig struct {
-	VideoFile     string      `json:"video_file"`
-	DataFile      []string    `json:"data_file"`
-	OutputPath    string      `json:"output_path"`
-	Overwrite     bool        `json:"overwrite"`
-	Font          string      `json:"font"`
-	VideoOnly     bool        `json:"video_only"`
-	Staff         []StaffItem `json:"staff"`
-	TyperInterval [2]int      `json:"typer_interval"`
-	Duration      [2]int      `json:"duration"`
-	Debug         bool        `json:"debug"`
+	VideoFile          string                 `json:"video_file"`
+	DataFile           []string               `json:"data_file"`
+	OutputPath         string                 `json:"output_path"`
+	Overwrite          bool                   `json:"overwrite"`
+	Font               string                 `json:"font"`
+	VideoOnly          bool                   `json:"video_only"`
+	Staff              []StaffItem            `json:"staff"`
+	TyperInterval      [2]int                 `json:"typer_interval"`
+	TyperMode          string                 `json:"typer_mode"`
+	Bilingual          string                 `json:"bilingual"`
+	SecondaryScale     float64                `json:"secondary_scale"`
+	SecondaryTyperMode string                 `json:"secondary_typer_mode"`
+	TyperAuto          string                 `json:"typer_auto"`
+	TyperPause         map[string]int         `json:"typer_pause"`
+	StyleTheme         string                 `json:"style_theme"`
+	Glossary           string                 `json:"glossary"`
+	Lint               bool                   `json:"lint"`
+	Wrap               bool                   `json:"wrap"`
+	WrapShrink         float64                `json:"wrap_shrink"`
+	FontFile           string                 `json:"font_file"`
+	FontIndex          int                    `json:"font_index"`
+	VoiceExport        string                 `json:"voice_export"`
+	VoiceComments      bool                   `json:"voice_comments"`
+	Duration           [2]int                 `json:"duration"`
+	Calibrate          bool                   `json:"calibrate"`
+	EffectTypes        []int                  `json:"effect_types"`
+	Corrections        string                 `json:"corrections"`
+	TimeOffset         int                    `json:"time_offset"`
+	TargetFps          float64                `json:"target_fps"`
+	UseTimestamps      bool                   `json:"use_timestamps"`
+	Tracks             map[string]TrackConfig `json:"tracks"`
+	Language           string                 `json:"language"`
+	Debug              bool                   `json:"debug"`
 }
 
 type Task struct {
-	Config     TaskConfig
-	Processing bool
-	Stopped    bool
-	Logs       []Log
-	LogChan    chan Log
-	Id         string
+	Config      TaskConfig
+	Processing  bool
+	Stopped     bool
+	Logs        []Log
+	LogChan     chan Log
+	Id          string
+	Thresholds  MatchThresholds
+	Corrections Corrections
+	Theme       StyleTheme
 }
 
 type Log struct {
@@ -590,74 +880,131 @@ type LogProgress struct {
 }
 
 func (t *Task) load() PJSTranslationData {
-	var result = PJSTranslationData{}
-	if len(t.Config.DataFile) > 1 {
-		result = MakePJSData(t.Config.DataFile[0], t.Config.DataFile[1])
+	var result = ReadStoryData(t.Config.DataFile)
+	switch storyFilesKind(t.Config.DataFile) {
+	case storyFilesLegacyText:
 		go t.Log(Log{Type: "string", Data: "[Initial] Loaded Legacy Json File and Text File."})
-	} else if len(t.Config.DataFile) == 1 {
-		if strings.HasSuffix(t.Config.DataFile[0], "pjs.txt") {
-			result = ReadPJSFile(t.Config.DataFile[0])
-			go t.Log(Log{Type: "string", Data: "[Initial] Loaded PJS Story File."})
-		} else {
-			result = MakePJSData(t.Config.DataFile[0], "")
-			go t.Log(Log{Type: "string", Data: "[Initial] Loaded Legacy Json File."})
-		}
-	} else {
+	case storyFilesPJS:
+		go t.Log(Log{Type: "string", Data: "[Initial] Loaded PJS Story File."})
+	case storyFilesLegacy:
+		go t.Log(Log{Type: "string", Data: "[Initial] Loaded Legacy Json File."})
+	default:
 		go t.Log(Log{Type: "string", Data: "[Initial] Using Empty Story Data"})
 	}
+	for _, issue := range result.Meta.Issues {
+		go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Warning] Story Asset: %s", issue)})
+	}
+	if result.Meta.ScenarioId != "" {
+		go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Initial] Scenario %s with %d Appearing Characters",
+			result.Meta.ScenarioId, len(result.Meta.CharacterIds))})
+	}
+	result = result.FilterEffects(t.Config.EffectTypes)
 	if result.Data.Count() > 0 {
 		go t.Log(Log{Type: "string",
-			Data: fmt.Sprintf("[Initial] Loaded %d Dialogs, %d Banners, %d Markers",
-				result.Dialogs().Count(), result.Banners().Count(), result.Markers().Count())})
+			Data: fmt.Sprintf("[Initial] Loaded %d Dialogs, %d Banners, %d Markers, %d Choices, %d Full Screen Texts",
+				result.Dialogs().Count(), result.Banners().Count(), result.Markers().Count(),
+				len(result.ChoicePrompts()), result.FullScreenTexts().Count())})
 	}
 	return result
 
 }
 
-func (t *Task) match() (
-	dialogTalkDataEvents, dialogCharacterEvents, bannerEvents, markerEvents []SubtitleEventItem, dialogStyles []SubtitleStyleItem, err error) {
+type matchResult struct {
+	videoHeight       int
+	videoWidth        int
+	frameTimeMs       float64
+	pointSize         int
+	contentStartFrame int
+	endFrame          int
+	dialogPointCenter image.Point
+	dialogFrameSet    [][]dialogFrame
+	bannerFrameSet    [][]bannerFrame
+	markerFrameSet    [][]markerFrame
+	choiceFrameSet    [][]choiceFrame
+	fullScreenTextSet [][]fullScreenTextFrame
+	dialogBoxSet      [][]dialogBoxFrame
+	dialogSignatures  [][]uint8
+	forcedLines       map[string]map[int]int
+	frameTimestamps   map[int]float64
+	revealSamples     map[int]float64
+}
+
+// storyIndex is the story index used for the i-th run of a kind, honouring forced corrections.
+func (m matchResult) storyIndex(kind string, i int) int {
+	if index, ok := m.forcedLines[kind][i]; ok {
+		return index
+	}
+	return i
+}
+
+type generateResult struct {
+	dialogEvents    []SubtitleEventItem
+	characterEvents []SubtitleEventItem
+	bannerEvents    []SubtitleEventItem
+	markerEvents    []SubtitleEventItem
+	choiceEvents    []SubtitleEventItem
+	fullScreenTexts []SubtitleEventItem
+	voiceEvents     []SubtitleEventItem
+	voiceLines      []voiceLine
+	styles          []SubtitleStyleItem
+}
+
+// detectorActive reports whether a detector should look at the current frame.
+// Without story data everything is scanned, otherwise effects are only looked
+// for while they are the next expected event before the next dialog. dialogProcessed
+// is the number of dialog lines finished so far, counted after boundary splitting.
+func (t *Task) detectorActive(d Detector, storyData PJSTranslationData, dialogProcessed int, videoCut bool) bool {
+	if t.Config.VideoOnly {
+		return true
+	}
+	if p, ok := d.(interface{ Pending() bool }); ok && p.Pending() {
+		return true
+	}
+	var kind, segments = d.Kind(), d.Segments()
+	if kind == "DialogBox" || kind == "DialogBoundary" || kind == "DialogReveal" {
+		kind, segments = "Dialog", dialogProcessed
+	}
+	if segments >= storyData.segmentCount(kind) {
+		return false
+	}
+	if kind == "Dialog" || videoCut {
+		return true
+	}
+	nextIndex := storyData.segmentIndex(kind, segments)
+	nextDialogIndex := storyData.Data.IndexType("Dialog", dialogProcessed)
+	return nextDialogIndex < 0 || nextIndex < nextDialogIndex
+}
+
+// videoSize is the frame height and width of the task video, 1080p when it cannot be read.
+func (t *Task) videoSize() (int, int) {
+	if FileExist(t.Config.VideoFile) {
+		if vc, err := gocv.VideoCaptureFile(t.Config.VideoFile); err == nil {
+			h, w := int(vc.Get(gocv.VideoCaptureFrameHeight)), int(vc.Get(gocv.VideoCaptureFrameWidth))
+			_ = vc.Close()
+			if h > 0 && w > 0 {
+				return h, w
+			}
+		}
+	}
+	return 1080, 1920
+}
 
+func (t *Task) match(storyData PJSTranslationData) (result matchResult, err error) {
 	timeStart := time.Now().UnixMilli()
 	var vc *gocv.VideoCapture
 
 	if FileExist(t.Config.VideoFile) {
 		vc, _ = gocv.VideoCaptureFile(t.Config.VideoFile)
 	} else {
-		return nil, nil, nil, nil, nil, errors.New("video File Not Exist")
+		return result, errors.New("video File Not Exist")
 	}
-	var StoryData = t.load()
 
 	var videoHeight = int(vc.Get(gocv.VideoCaptureFrameHeight))
 	var videoWidth = int(vc.Get(gocv.VideoCaptureFrameWidth))
 	var videoFps = vc.Get(gocv.VideoCaptureFPS)
 	var videoFrameCount = int(vc.Get(gocv.VideoCaptureFrameCount))
 
-	// Templates
-	var templateDialogPointer = getResizedDialogPointer(videoHeight, videoWidth)
-	var templateMenuSign = getResizedInterfaceMenu(videoHeight, videoWidth)
-	var templateMarker = getResizedAreaMarkerTemplate(videoHeight, videoWidth)
-	var templateBannerReverse = gocv.NewMat()
-	var templateBannerCanny = gocv.NewMat()
-	var bannerArea = getBannerArea(videoHeight, videoWidth)
-	{
-		var bannerEdge = getResizedAreaEdge(videoHeight, videoWidth)
-		s := int(math.Abs(float64(bannerArea[1] - bannerArea[0])))
-		gocv.Resize(bannerEdge, &bannerEdge, image.Point{X: s, Y: s}, 0, 0, gocv.InterpolationLanczos4)
-		gocv.Canny(bannerEdge, &templateBannerCanny, 50, 150)
-		gocv.Threshold(bannerEdge, &templateBannerReverse, 128.0, 255.0, gocv.ThresholdBinaryInv)
-		_ = bannerEdge.Close()
-	}
-
 	var contentStart = false
-	var markerProcessRunning = true
-	var bannerProcessRunning = true
-	var dialogProcessRunning = true
-	if !t.Config.VideoOnly {
-		markerProcessRunning = StoryData.Markers().Count() > 0
-		bannerProcessRunning = StoryData.Banners().Count() > 0
-		dialogProcessRunning = StoryData.Dialogs().Count() > 0
-	}
-
 	var totalFrameCount int
 	var videoCut = false
 	var nowFrameCount = 0
@@ -673,303 +1020,352 @@ func (t *Task) match() (
 		contentStart = true
 	}
 
-	var dialogFrameSet [][]dialogFrame
-	var bannerFrameSet [][]bannerFrame
-	var markerFrameSet [][]markerFrame
-	var dialogConstPointCenter image.Point
-	func() {
-		var bannerProcessingFrames []bannerFrame
-		var bannerProcessedCount = 0
-		var bannerLastResult = false
-
-		var markerProcessingFrames []markerFrame
-		var markerProcessedCount = 0
-		var markerLastResult image.Point
+	t.Thresholds = DefaultMatchThresholds
+	if t.Config.Calibrate {
+		t.Thresholds = t.calibrate(vc, videoHeight, videoWidth, nowFrameCount, nowFrameCount+totalFrameCount)
+	}
 
-		var dialogLastStatus = uint8(0)
-		var dialogProcessedCount = 0
-		var dialogProcessingFrames []dialogFrame
-		var dialogLastPointCenter = image.Point{}
+	var frameTimestamps = map[int]float64{}
+	var onSegment = func(kind string, index, length int) {
+		go t.Log(Log{
+			Type: "string",
+			Data: fmt.Sprintf("[Processing] Locate %d Frames for %s No.%d", length, kind, index),
+		})
+	}
+	var menu = newMenuDetector(t.Thresholds.Menu, nil)
+	var dialog = newDialogDetector(t.Thresholds.Pointer, nil)
+	var banner = newBannerDetector(t.Thresholds.Banner, onSegment)
+	var marker = newMarkerDetector(t.Thresholds.Marker, onSegment)
+	var choice = newChoiceDetector(onSegment)
+	var fullScreenText = newFullScreenTextDetector(onSegment)
+	var dialogBox = newDialogBoxDetector(nil)
+	var dialogBoundary = newDialogBoundaryDetector(nil)
+	var dialogReveal = newDialogRevealDetector(nil)
+	var dialogLines dialogLineCounter
+	var detectors = []Detector{dialog, dialogBox, dialogBoundary, banner, marker, choice, fullScreenText}
+	switch t.Config.TyperAuto {
+	case TyperAutoLine, TyperAutoVideo:
+		detectors = append(detectors, dialogReveal)
+	case "":
+	default:
+		go t.Log(Log{Type: "string",
+			Data: fmt.Sprintf("[Warning] Unknown Typer Auto Mode %q, Using Fixed Typer Speed", t.Config.TyperAuto)})
+	}
+	menu.Init(videoHeight, videoWidth)
+	for _, d := range detectors {
+		d.Init(videoHeight, videoWidth)
+	}
 
-		for {
-			if t.Stopped {
-				setStopped = true
-				break
-			}
-			var frame = gocv.NewMat()
-			vc.Read(&frame)
-			if frame.Empty() {
-				break
-			}
+	for {
+		if t.Stopped {
+			setStopped = true
+			break
+		}
+		var frame = gocv.NewMat()
+		vc.Read(&frame)
+		if frame.Empty() {
+			break
+		}
 
-			gocv.CvtColor(frame, &frame, gocv.ColorBGRToGray)
-			if !contentStart {
-				contentStart = matchCheckStart(frame, templateMenuSign)
+		if t.Config.UseTimestamps {
+			if ms := vc.Get(gocv.VideoCapturePosMsec); ms > 0 || nowFrameCount == 0 {
+				frameTimestamps[nowFrameCount] = ms
 			}
+		}
+		gocv.CvtColor(frame, &frame, gocv.ColorBGRToGray)
+		if !contentStart {
+			menu.Process(frame, nowFrameCount)
+			contentStart = menu.Started()
 			if contentStart {
-				var running = true
-				if !t.Config.VideoOnly {
-					if StoryData.Dialogs().Count() == dialogProcessedCount &&
-						StoryData.Banners().Count() == bannerProcessedCount &&
-						StoryData.Markers().Count() == markerProcessedCount {
-						running = false
-					}
-				}
-				if running {
-					var dialogProcessFrame gocv.Mat
-					if dialogProcessRunning {
-						dialogProcessFrame = frame.Clone()
-					}
-
-					var bannerProcessNow = false
-					var bannerProcessFrame gocv.Mat
-					if bannerProcessRunning {
-						if videoCut || t.Config.VideoOnly {
-							bannerProcessNow = true
-						} else if bannerProcessedCount < StoryData.Banners().Count() {
-							if StoryData.Data.IndexType("Banner", bannerProcessedCount) < StoryData.Data.IndexType("Dialog", dialogProcessedCount) {
-								bannerProcessNow = true
-							}
-						}
-						if bannerProcessNow {
-							bannerProcessFrame = frame.Clone()
-						}
-					}
-
-					var markerProcessNow = false
-					var markerProcessFrame gocv.Mat
-					if markerProcessRunning {
-						if videoCut || t.Config.VideoOnly {
-							markerProcessNow = true
-						} else if markerProcessedCount < StoryData.Markers().Count() {
-							if StoryData.Data.IndexType("Marker", bannerProcessedCount) < StoryData.Data.IndexType("Dialog", dialogProcessedCount) {
-								markerProcessNow = true
-							}
-						}
-						if markerProcessNow {
-							markerProcessFrame = frame.Clone()
-						}
-					}
-
-					var group = sync.WaitGroup{}
-					group.Add(3)
-					go func() {
-						if dialogProcessRunning {
-							dialogProcessResult := matchFrameDialog(dialogProcessFrame, templateDialogPointer, dialogLastPointCenter)
-							if dialogConstPointCenter.Eq(image.Point{}) {
-								if dialogProcessResult.status == 2 {
-									dialogConstPointCenter = dialogProcessResult.pointCenter
-								}
-							}
-							if dialogProcessResult.status != 2 && dialogLastStatus == 2 {
-								dialogFrameSet = append(dialogFrameSet, dialogProcessingFrames)
-								dialogProcessedCount = len(dialogFrameSet)
-
-								go t.Log(Log{
-									Type: "string",
-									Data: fmt.Sprintf("[Processing] Locate %d Frames for Dialog No.%d", len(dialogProcessingFrames), dialogProcessedCount),
-								})
-
-								dialogProcessingFrames = []dialogFrame{}
-								if !t.Config.VideoOnly && dialogProcessedCount == StoryData.Dialogs().Count() {
-									dialogProcessRunning = false
-								}
-							}
-							if dialogProcessResult.status != 0 {
-								dialogProcessingFrames = append(dialogProcessingFrames, dialogFrame{
-									FrameId: nowFrameCount, PointCenter: dialogProcessResult.pointCenter})
-							}
-							dialogLastStatus = dialogProcessResult.status
-							dialogLastPointCenter = dialogProcessResult.pointCenter
-							_ = dialogProcessFrame.Close()
-						}
-						group.Done()
-					}()
-					go func() {
-						if bannerProcessNow {
-							bannerProcessResult := matchFrameBanner(bannerProcessFrame, templateBannerCanny, templateBannerReverse, bannerArea)
-							if bannerProcessResult {
-								bannerProcessingFrames = append(bannerProcessingFrames, bannerFrame{FrameId: nowFrameCount})
-							}
-							if bannerLastResult && !bannerProcessResult {
-								bannerFrameSet = append(bannerFrameSet, bannerProcessingFrames)
-								bannerProcessedCount = len(bannerFrameSet)
-
-								go t.Log(Log{
-									Type: "string",
-									Data: fmt.Sprintf("[Processing] Locate %d Frames for Banner No.%d", len(bannerProcessingFrames), bannerProcessedCount),
-								})
-
-								bannerProcessingFrames = []bannerFrame{}
-								if !t.Config.VideoOnly && bannerProcessedCount == StoryData.Banners().Count() {
-									bannerProcessRunning = false
-								}
-							}
-							bannerLastResult = bannerProcessResult
-							_ = bannerProcessFrame.Close()
-						}
-						group.Done()
-					}()
-					go func() {
-						if markerProcessNow {
-							markerProcessResult := matchFrameMarker(markerProcessFrame, templateMarker)
-							if !markerProcessResult.Eq(image.Point{}) {
-								markerProcessingFrames = append(markerProcessingFrames,
-									markerFrame{Position: markerProcessResult, FrameId: nowFrameCount})
-							}
-							if !markerLastResult.Eq(image.Point{}) && markerProcessResult.Eq(image.Point{}) {
-								markerFrameSet = append(markerFrameSet, markerProcessingFrames)
-								markerProcessedCount = len(markerFrameSet)
-
-								go t.Log(Log{
-									Type: "string",
-									Data: fmt.Sprintf("[Processing] Locate %d Frames for Marker No.%d", len(markerProcessingFrames), markerProcessedCount),
-								})
-
-								markerProcessingFrames = []markerFrame{}
-								if !t.Config.VideoOnly && markerProcessedCount == StoryData.Markers().Count() {
-									markerProcessRunning = false
-								}
-							}
-							markerLastResult = markerProcessResult
-							_ = markerProcessFrame.Close()
-						}
-						group.Done()
-					}()
-					group.Wait()
-				}
-			}
-			_ = frame.Close()
-
-			nowFrameCount += 1
-			lp := LogProgress{
-				Frame:    nowFrameCount,
-				Time:     int(time.Now().UnixMilli() - timeStart),
-				Remains:  totalFrameCount + t.Config.Duration[0] - nowFrameCount,
-				Progress: float64(nowFrameCount-t.Config.Duration[0]) / float64(totalFrameCount),
-				Speed:    float64(nowFrameCount) / (float64(time.Now().UnixMilli()-timeStart) / 1000.0),
+				go t.Log(Log{Type: "string",
+					Data: fmt.Sprintf("[Processing] Story Content Started at Frame %d", nowFrameCount)})
 			}
-			lp.Fps = float64(lp.Frame-fpsTimeCounter[0].Frame) / float64(lp.Time-fpsTimeCounter[0].Time) * 1000.0
-			if len(fpsTimeCounter) == int(videoFps/2.0) || fpsTimeCounter[0].Frame == 0 {
-				fpsTimeCounter = append(fpsTimeCounter[1:], lp)
-			} else {
-				fpsTimeCounter = append(fpsTimeCounter, lp)
-			}
-			l, _ := json.Marshal(lp)
-			go t.Log(Log{Type: "dict", Data: string(l)})
-			if nowFrameCount-t.Config.Duration[0] > totalFrameCount {
-				break
+		}
+		if contentStart {
+			dialogBox.SetAnchor(dialog.constPointCenter)
+			dialogBoundary.SetAnchor(dialog.lastPointCenter, dialog.PointSize())
+			dialogReveal.SetAnchor(dialog.constPointCenter)
+			var group = sync.WaitGroup{}
+			var dialogProcessed = dialogLines.count(dialog.segments, dialog.processing, dialogBoundary.segments)
+			for _, d := range detectors {
+				if !t.detectorActive(d, storyData, dialogProcessed, videoCut) {
+					continue
+				}
+				group.Add(1)
+				go func(d Detector, frame gocv.Mat, frameId int) {
+					d.Process(frame, frameId)
+					_ = frame.Close()
+					group.Done()
+				}(d, frame.Clone(), nowFrameCount)
 			}
+			group.Wait()
+		}
+		_ = frame.Close()
+
+		nowFrameCount += 1
+		lp := LogProgress{
+			Frame:    nowFrameCount,
+			Time:     int(time.Now().UnixMilli() - timeStart),
+			Remains:  totalFrameCount + t.Config.Duration[0] - nowFrameCount,
+			Progress: float64(nowFrameCount-t.Config.Duration[0]) / float64(totalFrameCount),
+			Speed:    float64(nowFrameCount) / (float64(time.Now().UnixMilli()-timeStart) / 1000.0),
+		}
+		lp.Fps = float64(lp.Frame-fpsTimeCounter[0].Frame) / float64(lp.Time-fpsTimeCounter[0].Time) * 1000.0
+		if len(fpsTimeCounter) == int(videoFps/2.0) || fpsTimeCounter[0].Frame == 0 {
+			fpsTimeCounter = append(fpsTimeCounter[1:], lp)
+		} else {
+			fpsTimeCounter = append(fpsTimeCounter, lp)
 		}
-	}()
-	var videoFrameTimeMs = 1000.0 / videoFps
+		l, _ := json.Marshal(lp)
+		go t.Log(Log{Type: "dict", Data: string(l)})
+		if nowFrameCount-t.Config.Duration[0] > totalFrameCount {
+			break
+		}
+	}
+	for _, d := range detectors {
+		d.Finalize()
+	}
+
+	dialogRuns, dialogSignatures := t.splitDialogRuns(dialog.segments, dialog.signatures, dialogBoundary.segments)
+	var forcedLines = map[string]map[int]int{}
+	var dialogOrigins []int
+	dialogRuns, dialogOrigins, forcedLines["Dialog"] = applyRunCorrections(t, "Dialog", dialogRuns,
+		func(f dialogFrame) int { return f.FrameId },
+		func(f dialogFrame, id int) dialogFrame { f.FrameId = id; return f })
+	var correctedSignatures [][]uint8
+	for _, origin := range dialogOrigins {
+		correctedSignatures = append(correctedSignatures, dialogSignatures[origin])
+	}
+	bannerRuns, _, bannerLines := applyRunCorrections(t, "Banner", banner.segments,
+		func(f bannerFrame) int { return f.FrameId },
+		func(f bannerFrame, id int) bannerFrame { f.FrameId = id; return f })
+	markerRuns, _, markerLines := applyRunCorrections(t, "Marker", marker.segments,
+		func(f markerFrame) int { return f.FrameId },
+		func(f markerFrame, id int) markerFrame { f.FrameId = id; return f })
+	choiceRuns, _, choiceLines := applyRunCorrections(t, "Choice", choice.segments,
+		func(f choiceFrame) int { return f.FrameId },
+		func(f choiceFrame, id int) choiceFrame { f.FrameId = id; return f })
+	fullScreenTextRuns, _, fullScreenTextLines := applyRunCorrections(t, "FullScreenText", fullScreenText.segments,
+		func(f fullScreenTextFrame) int { return f.FrameId },
+		func(f fullScreenTextFrame, id int) fullScreenTextFrame { f.FrameId = id; return f })
+	forcedLines["Banner"], forcedLines["Marker"] = bannerLines, markerLines
+	forcedLines["Choice"], forcedLines["FullScreenText"] = choiceLines, fullScreenTextLines
+
+	var revealSamples = map[int]float64{}
+	for _, segment := range dialogReveal.segments {
+		for _, sample := range segment {
+			revealSamples[sample.FrameId] = sample.Ink
+		}
+	}
+
+	result = matchResult{
+		videoHeight:       videoHeight,
+		videoWidth:        videoWidth,
+		frameTimeMs:       1000.0 / videoFps,
+		pointSize:         dialog.PointSize(),
+		contentStartFrame: menu.StartFrame(),
+		endFrame:          nowFrameCount,
+		dialogPointCenter: dialog.constPointCenter,
+		dialogFrameSet:    dialogRuns,
+		bannerFrameSet:    bannerRuns,
+		markerFrameSet:    markerRuns,
+		choiceFrameSet:    choiceRuns,
+		fullScreenTextSet: fullScreenTextRuns,
+		dialogBoxSet:      dialogBox.segments,
+		dialogSignatures:  correctedSignatures,
+		forcedLines:       forcedLines,
+		frameTimestamps:   frameTimestamps,
+		revealSamples:     revealSamples,
+	}
+	if videoCut {
+		result.contentStartFrame = t.Config.Duration[0]
+	}
+
+	menu.Close()
+	for _, d := range detectors {
+		d.Close()
+	}
+	_ = vc.Close()
+	if setStopped {
+		err = errors.New("process was Stopped")
+	}
+	return
+}
+
+func (t *Task) generate(storyData PJSTranslationData, matched matchResult) (generated generateResult, err error) {
+	var dialogTalkDataEvents, dialogCharacterEvents, bannerEvents, markerEvents, choiceEvents []SubtitleEventItem
+	var fullScreenTextEvents, voiceEvents []SubtitleEventItem
+	var voiceLines []voiceLine
+	var videoHeight, videoWidth = matched.videoHeight, matched.videoWidth
+	var tl = t.timeline(matched)
+	storyData = t.applyGlossary(storyData)
+	if t.Config.Wrap && t.Config.FontFile != "" {
+		if _, err := cachedFontMetrics(t.Config.FontFile, t.Config.FontIndex); err != nil {
+			go t.Log(Log{Type: "string",
+				Data: fmt.Sprintf("[Warning] Estimating Text Widths, Font Unreadable: %s", err.Error())})
+		}
+	}
+	var dialogFrameSet = matched.dialogFrameSet
+	var bannerFrameSet = matched.bannerFrameSet
+	var markerFrameSet = matched.markerFrameSet
 	var bannerMask = getAreaBannerMask(getAreaMaskSize(videoHeight, videoWidth))
+	var dialogPhases = computeDialogPhases(matched.dialogBoxSet, dialogFrameSet)
+	var alignedDialogs = t.alignDialogs(storyData, matched, dialogPhases)
+	styles, err := t.Theme.Apply(dialogMakeStyle(t.Config, matched.dialogPointCenter, matched.pointSize))
+	if err != nil {
+		return
+	}
+	var typerCharTimes = t.typerCharTimes(storyData, alignedDialogs, matched.revealSamples, tl)
 
-	if !setStopped {
-		for i, frames := range dialogFrameSet {
-			var dialogData StoryEvent
-			if !t.Config.VideoOnly && i < StoryData.Dialogs().Count() {
-				dialogData = StoryData.Dialogs()[i]
+	for i, aligned := range alignedDialogs {
+		var frames = aligned.Frames
+		var dialogData StoryEvent
+		if aligned.Line >= 0 {
+			dialogData = storyData.Dialogs()[aligned.Line]
+		}
+		var dialogLastEndFrame dialogFrame
+		var dialogLastEndEvent SubtitleEventItem
+		if i > 0 {
+			lfs := alignedDialogs[i-1].Frames
+			if len(lfs) > 0 {
+				dialogLastEndFrame = lfs[len(lfs)-1]
 			}
-			var dialogLastEndFrame dialogFrame
-			var dialogLastEndEvent SubtitleEventItem
-			if i > 0 {
-				lfs := dialogFrameSet[i-1]
-				if len(lfs) > 0 {
-					dialogLastEndFrame = lfs[len(lfs)-1]
-				}
-				if len(dialogTalkDataEvents) > 0 {
-					dialogLastEndEvent = dialogTalkDataEvents[len(dialogTalkDataEvents)-1]
-				}
+			if len(dialogTalkDataEvents) > 0 {
+				dialogLastEndEvent = dialogTalkDataEvents[len(dialogTalkDataEvents)-1]
 			}
+		}
 
-			var dialogIsMaskStart bool
-			if !t.Config.VideoOnly {
-				index := Index(dialogData, StoryData.DPeriod())
-				if index > 0 {
-					dialogIsMaskStart = StoryData.DPeriod()[index-1].Type == "Period"
-				} else if index == 0 {
+		var dialogIsMaskStart bool
+		if aligned.Line >= 0 {
+			dialogIsMaskStart = storyData.DialogOpensBox(aligned.Line)
+		} else if aligned.Phase.opens() {
+			dialogIsMaskStart = true
+		} else {
+			if i > 0 {
+				lfs := alignedDialogs[i-1].Frames
+				if len(lfs) > 0 && len(frames) > 0 && lfs[len(lfs)-1].FrameId == frames[0].FrameId-1 {
 					dialogIsMaskStart = true
 				}
-			} else {
-				if i > 0 {
-					lfs := dialogFrameSet[i-1]
-					if len(lfs) > 0 && len(frames) > 0 && lfs[len(lfs)-1].FrameId == frames[0].FrameId-1 {
-						dialogIsMaskStart = true
-					}
-				}
 			}
-
-			characterMasks, characterEvents, dialogMasks, dialogEvents := dialogMakeEvent(
-				dialogData, templateDialogPointer.Cols(), videoHeight, videoWidth, videoFrameTimeMs, dialogLastEndFrame,
-				frames, dialogLastEndEvent, dialogIsMaskStart, t.Config)
-
-			dialogTalkDataEvents = append(dialogTalkDataEvents, dialogMasks...)
-			dialogTalkDataEvents = append(dialogTalkDataEvents, dialogEvents...)
-			dialogCharacterEvents = append(dialogCharacterEvents, characterMasks...)
-			dialogCharacterEvents = append(dialogCharacterEvents, characterEvents...)
-
-			go t.Log(Log{Type: "string",
-				Data: fmt.Sprintf("[Processing] Generated %d Events for Dialog No.%d",
-					len(characterMasks)+len(characterEvents)+len(dialogMasks)+len(dialogEvents), i+1)})
 		}
-		for i, frames := range bannerFrameSet {
-			var bannerData StoryEvent
-			if !t.Config.VideoOnly && i < StoryData.Banners().Count() {
-				bannerData = StoryData.Banners()[i]
-			}
-			events := bannerMakeEvent(bannerData, bannerMask, videoFrameTimeMs, frames)
-			bannerEvents = append(bannerEvents, events...)
+		if aligned.Phase.opens() || aligned.Phase.closes() {
 			go t.Log(Log{Type: "string",
-				Data: fmt.Sprintf("[Processing] Generated %d Events for Banner No.%d", len(events), i+1),
-			})
+				Data: fmt.Sprintf("[Processing] Dialog No.%d Box Open %d Frames, Close %d Frames", i+1,
+					aligned.Phase.OpenEnd-aligned.Phase.OpenStart, aligned.Phase.CloseEnd-aligned.Phase.CloseStart)})
 		}
-		for i, frames := range markerFrameSet {
-			var markerData StoryEvent
-			if !t.Config.VideoOnly && i < StoryData.Markers().Count() {
-				markerData = StoryData.Markers()[i]
+
+		var lineConfig = t.Config
+		lineConfig.TyperInterval[1] = typerCharTimes[i]
+		characterMasks, characterEvents, dialogMasks, dialogEvents := dialogMakeEvent(
+			dialogData, matched.pointSize, videoHeight, videoWidth, tl, dialogLastEndFrame,
+			frames, dialogLastEndEvent, dialogIsMaskStart, aligned.Phase, lineConfig, styles)
+
+		dialogTalkDataEvents = append(dialogTalkDataEvents, dialogMasks...)
+		dialogTalkDataEvents = append(dialogTalkDataEvents, dialogEvents...)
+		dialogCharacterEvents = append(dialogCharacterEvents, characterMasks...)
+		dialogCharacterEvents = append(dialogCharacterEvents, characterEvents...)
+		if len(frames) > 0 {
+			voice := makeVoiceLine(i+1, aligned.Line+1, dialogData, tl, frames)
+			voiceLines = append(voiceLines, voice)
+			if t.Config.VoiceComments && voice.VoiceId != "" {
+				voiceEvents = append(voiceEvents, voiceComment(voice))
 			}
-			events := markerMakeEvent(markerData, videoHeight, videoWidth, videoFrameTimeMs, frames)
-			markerEvents = append(markerEvents, events...)
-			go t.Log(Log{
-				Type: "string",
-				Data: fmt.Sprintf("[Processing] Generated %d Events for Banner No.%d", len(events), i+1),
-			})
 		}
+
+		go t.Log(Log{Type: "string",
+			Data: fmt.Sprintf("[Processing] Generated %d Events for Dialog No.%d",
+				len(characterMasks)+len(characterEvents)+len(dialogMasks)+len(dialogEvents), i+1)})
+	}
+	for i, frames := range bannerFrameSet {
+		var bannerData StoryEvent
+		if index := matched.storyIndex("Banner", i); !t.Config.VideoOnly && index < storyData.Banners().Count() {
+			bannerData = storyData.Banners()[index]
+		}
+		events := bannerMakeEvent(bannerData, bannerMask, tl, frames, t.Config)
+		bannerEvents = append(bannerEvents, events...)
+		go t.Log(Log{Type: "string",
+			Data: fmt.Sprintf("[Processing] Generated %d Events for Banner No.%d", len(events), i+1),
+		})
+	}
+	for i, frames := range markerFrameSet {
+		var markerData StoryEvent
+		if index := matched.storyIndex("Marker", i); !t.Config.VideoOnly && index < storyData.Markers().Count() {
+			markerData = storyData.Markers()[index]
+		}
+		events := markerMakeEvent(markerData, videoHeight, videoWidth, tl, frames, t.Config)
+		markerEvents = append(markerEvents, events...)
+		go t.Log(Log{
+			Type: "string",
+			Data: fmt.Sprintf("[Processing] Generated %d Events for Marker No.%d", len(events), i+1),
+		})
 	}
 
-	if !setStopped {
-		if len(dialogTalkDataEvents)+len(dialogCharacterEvents)+len(bannerEvents)+len(markerEvents) == 0 {
-			err = errors.New("no Event Matched")
-		} else {
-			dialogStyles = dialogMakeStyle(t.Config, dialogConstPointCenter, templateDialogPointer.Cols())
-			if !t.Config.VideoOnly {
-				var recheck []string
-				if len(dialogFrameSet) != StoryData.Dialogs().Count() {
-					recheck = append(recheck, "Dialog")
-				}
-				if len(bannerFrameSet) != StoryData.Banners().Count() {
-					recheck = append(recheck, "Banner")
-				}
-				if len(markerFrameSet) != StoryData.Markers().Count() {
-					recheck = append(recheck, "Marker")
-				}
-				if len(recheck) > 0 {
-					go t.Log(Log{Type: "string",
-						Data: fmt.Sprintf("[Warning] Unmatched Event Exists:%s", strings.Join(recheck, ","))})
-				}
-			}
-			err = nil
+	var choicePrompts = storyData.ChoicePrompts()
+	for i, frames := range matched.choiceFrameSet {
+		var options StoryEventSet
+		if index := matched.storyIndex("Choice", i); !t.Config.VideoOnly && index < len(choicePrompts) {
+			options = choicePrompts[index]
 		}
-	} else {
-		err = errors.New("process was Stopped")
+		events := choiceMakeEvent(options, tl, frames)
+		choiceEvents = append(choiceEvents, events...)
+		go t.Log(Log{
+			Type: "string",
+			Data: fmt.Sprintf("[Processing] Generated %d Events for Choice No.%d", len(events), i+1),
+		})
+	}
+
+	for i, frames := range matched.fullScreenTextSet {
+		var textData StoryEvent
+		if index := matched.storyIndex("FullScreenText", i); !t.Config.VideoOnly && index < storyData.FullScreenTexts().Count() {
+			textData = storyData.FullScreenTexts()[index]
+		}
+		events := fullScreenTextMakeEvent(textData, videoHeight, videoWidth, tl, frames)
+		fullScreenTextEvents = append(fullScreenTextEvents, events...)
+		go t.Log(Log{
+			Type: "string",
+			Data: fmt.Sprintf("[Processing] Generated %d Events for Full Screen Text No.%d", len(events), i+1),
+		})
 	}
 
-	_ = templateDialogPointer.Close()
-	_ = templateBannerCanny.Close()
-	_ = templateBannerReverse.Close()
-	_ = templateMenuSign.Close()
-	_ = templateMarker.Close()
+	generated = generateResult{
+		dialogEvents:    dialogTalkDataEvents,
+		characterEvents: dialogCharacterEvents,
+		bannerEvents:    bannerEvents,
+		markerEvents:    markerEvents,
+		choiceEvents:    choiceEvents,
+		fullScreenTexts: fullScreenTextEvents,
+		voiceEvents:     voiceEvents,
+		voiceLines:      voiceLines,
+	}
+	if len(dialogTalkDataEvents)+len(dialogCharacterEvents)+len(bannerEvents)+len(markerEvents)+
+		len(choiceEvents)+len(fullScreenTextEvents) == 0 {
+		err = errors.New("no Event Matched")
+	} else {
+		generated.styles = styles
+		if !t.Config.VideoOnly {
+			var recheck []string
+			if len(dialogFrameSet) != storyData.Dialogs().Count() {
+				recheck = append(recheck, "Dialog")
+			}
+			if len(bannerFrameSet) != storyData.Banners().Count() {
+				recheck = append(recheck, "Banner")
+			}
+			if len(markerFrameSet) != storyData.Markers().Count() {
+				recheck = append(recheck, "Marker")
+			}
+			if len(matched.choiceFrameSet) != len(choicePrompts) {
+				recheck = append(recheck, "Choice")
+			}
+			if len(matched.fullScreenTextSet) != storyData.FullScreenTexts().Count() {
+				recheck = append(recheck, "FullScreenText")
+			}
+			if len(recheck) > 0 {
+				go t.Log(Log{Type: "string",
+					Data: fmt.Sprintf("[Warning] Unmatched Event Exists:%s", strings.Join(recheck, ","))})
+			}
+		}
+		err = nil
+	}
 	return
 }
 func (t *Task) Run() {
@@ -978,61 +1374,152 @@ func (t *Task) Run() {
 
 	timeStart := time.Now().UnixMilli()
 	go t.Log(Log{Type: "string", Data: "[Processing] Process Started"})
-	dialogsEvents, charactersEvents, bannerEvents, markerEvents, dialogStyles, err := t.match()
+	var storyData PJSTranslationData
+	var matched matchResult
+	var err error
+	if len(t.Config.DataFile) > 0 || len(t.Config.Tracks) == 0 {
+		storyData = t.load()
+	} else {
+		storyData = t.tracks()[0].load()
+	}
+	outputPath := t.Config.OutputPath
+	defer func() { t.Config.OutputPath = outputPath }()
+	t.Config.OutputPath = t.outputName(storyData.Meta, outputPath)
+	t.Corrections = t.loadCorrections()
+	if t.Config.Lint {
+		h, w := t.videoSize()
+		t.lint(storyData, h, w)
+	}
+	t.Theme, err = t.loadStyleTheme()
+	if err == nil {
+		matched, err = t.match(storyData)
+	}
 	if err != nil {
 		go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Error] Process Failed: %s", err.Error())})
-	} else {
-		go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Finish] Process Finished in %ds", (time.Now().UnixMilli()-timeStart)/1000)})
-		var staffEvents []SubtitleEventItem
-		var staffStyle []SubtitleStyleItem
-		for _, staff := range t.Config.Staff {
-			e, s := makeStaffEvent(staff, dialogStyles[0].Fontsize, dialogStyles[0].FontName)
-			staffEvents = append(staffEvents, e)
-			staffStyle = append(staffStyle, s)
-		}
-		filename := path.Base(t.Config.VideoFile)
-		events := []SubtitleEventItem{getDividerSubtitleEvent(filename+" - Made by SekaiSubtitle", 5)}
-		events = append(events, GetSubtitleArraySurrounded(staffEvents, "Staff", 15)...)
-		events = append(events, GetSubtitleArraySurrounded(bannerEvents, "Banner", 15)...)
-		events = append(events, GetSubtitleArraySurrounded(markerEvents, "Marker", 15)...)
-		events = append(events, GetSubtitleArraySurrounded(charactersEvents, "Character", 15)...)
-		events = append(events, GetSubtitleArraySurrounded(dialogsEvents, "Dialog", 15)...)
-
-		vc, err := gocv.VideoCaptureFile(t.Config.VideoFile)
+		t.Processing = false
+		return
+	}
+	tracks := t.tracks()
+	written := 0
+	for _, track := range tracks {
+		story := storyData
+		if language := track.Config.Language; language != "" {
+			go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Processing] Generating Track %s", language)})
+			if len(t.Config.Tracks[language].DataFile) > 0 {
+				story = track.load()
+				if t.Config.Lint {
+					track.lint(story, matched.videoHeight, matched.videoWidth)
+				}
+			}
+			track.Config.OutputPath = track.outputName(story.Meta, track.Config.OutputPath)
+			track.Theme, err = track.loadStyleTheme()
+		}
+		var generated generateResult
+		if err == nil {
+			generated, err = track.generate(story, matched)
+		}
 		if err != nil {
 			go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Error] Process Failed: %s", err.Error())})
+			err = nil
+			continue
 		}
-		res := Subtitle{
-			ScriptInfo: SubtitleScriptInfo{
-				Title: filename, ScriptType: "v4.00+",
-				PlayRexX: int(vc.Get(gocv.VideoCaptureFrameWidth)),
-				PlayRexY: int(vc.Get(gocv.VideoCaptureFrameHeight))},
-			Garbage: SubtitleGarbage{AudioFile: filename, VideoFile: filename},
-			Styles:  SubtitleStyles{Items: append(dialogStyles, staffStyle...)},
-			Events:  SubtitleEvents{Items: events},
-		}
-
-		exists := FileExist(t.Config.OutputPath)
-		con := false
-		if exists {
-			if t.Config.Overwrite {
-				con = true
-				go t.Log(Log{Type: "string", Data: "[Finish] Overwriting Existed File"})
-			}
-		} else {
+		if track.output(generated, matched) {
+			written += 1
+		}
+	}
+	go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Finish] Process Finished in %ds", (time.Now().UnixMilli()-timeStart)/1000)})
+	if len(tracks) > 1 {
+		go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Finish] Written %d of %d Tracks", written, len(tracks))})
+		go t.Log(Log{Type: "string", Data: "[Finish] Process Finished"})
+	}
+	t.Processing = false
+}
+
+// output writes the subtitle file of a generated track and reports whether it was written.
+func (t *Task) output(generated generateResult, matched matchResult) bool {
+	var dialogStyles = generated.styles
+	var staffEvents []SubtitleEventItem
+	var staffStyle []SubtitleStyleItem
+	var tl = t.timeline(matched)
+	for i, staff := range t.Config.Staff {
+		start, end, err := t.staffSpan(staff, matched, tl)
+		var avoid = err == nil && staff.AvoidDialog && overlapsDialog(matched, tl, start, end)
+		if avoid {
+			go t.Log(Log{Type: "string",
+				Data: fmt.Sprintf("[Processing] Staff No.%d Moved Above Dialog Box", i+1)})
+		}
+		var e SubtitleEventItem
+		var s SubtitleStyleItem
+		if err == nil {
+			e, s, err = makeStaffEvent(staff, start, end, avoid, dialogStyles[0].Fontsize, dialogStyles[0].FontName)
+		}
+		if err != nil {
+			go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Warning] Skipped Staff Credit: %s", err.Error())})
+			continue
+		}
+		staffEvents = append(staffEvents, e)
+		staffStyle = append(staffStyle, s)
+	}
+	filename := path.Base(t.Config.VideoFile)
+	events := []SubtitleEventItem{getDividerSubtitleEvent(filename+" - Made by SekaiSubtitle", 5)}
+	if t.Config.Calibrate {
+		events = append(events, getDividerSubtitleEvent("Thresholds: "+t.Thresholds.String(), 5))
+	}
+	events = append(events, GetSubtitleArraySurrounded(staffEvents, "Staff", 15)...)
+	events = append(events, GetSubtitleArraySurrounded(generated.bannerEvents, "Banner", 15)...)
+	events = append(events, GetSubtitleArraySurrounded(generated.markerEvents, "Marker", 15)...)
+	events = append(events, GetSubtitleArraySurrounded(generated.choiceEvents, "Choice", 15)...)
+	events = append(events, GetSubtitleArraySurrounded(generated.fullScreenTexts, "FullScreenText", 15)...)
+	events = append(events, GetSubtitleArraySurrounded(generated.characterEvents, "Character", 15)...)
+	events = append(events, GetSubtitleArraySurrounded(generated.dialogEvents, "Dialog", 15)...)
+	if len(generated.voiceEvents) > 0 {
+		events = append(events, GetSubtitleArraySurrounded(generated.voiceEvents, "Voice", 15)...)
+	}
+
+	res := Subtitle{
+		ScriptInfo: SubtitleScriptInfo{
+			Title: filename, ScriptType: "v4.00+",
+			PlayRexX: matched.videoWidth,
+			PlayRexY: matched.videoHeight},
+		Garbage: SubtitleGarbage{AudioFile: filename, VideoFile: filename},
+		Styles:  SubtitleStyles{Items: append(dialogStyles, staffStyle...)},
+		Events:  SubtitleEvents{Items: events},
+	}
+
+	exists := FileExist(t.Config.OutputPath)
+	con := false
+	if exists {
+		if t.Config.Overwrite {
 			con = true
+			go t.Log(Log{Type: "string", Data: "[Finish] Overwriting Existed File"})
+		}
+	} else {
+		con = true
+	}
+	if con {
+		WriteFileString(t.Config.OutputPath, res.string())
+		if t.Config.VoiceExport != "" {
+			t.exportVoices(generated.voiceLines)
 		}
-		if con {
-			WriteFileString(t.Config.OutputPath, res.string())
+		if t.Config.Language == "" {
 			go t.Log(Log{Type: "string", Data: "[Finish] Process Finished"})
 		} else {
-			go t.Log(Log{Type: "string", Data: "[Finish] Skipped Output Because of File Exists"})
-		}
-		if vc.Close() != nil {
-			go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Error] Process Failed: %s", err.Error())})
+			go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Finish] Track %s Written", t.Config.Language)})
 		}
+	} else {
+		go t.Log(Log{Type: "string", Data: "[Finish] Skipped Output Because of File Exists"})
 	}
-	t.Processing = false
+	return con
+}
+
+// outputName fills the output path from the story metadata and warns about placeholders left empty.
+func (t *Task) outputName(meta StoryMetadata, path string) string {
+	result, missing := meta.OutputName(path)
+	if len(missing) > 0 {
+		go t.Log(Log{Type: "string",
+			Data: fmt.Sprintf("[Warning] No Story Metadata for %s in Output Path", strings.Join(missing, ", "))})
+	}
+	return result
 }
 func (t *Task) Log(log Log) {
 	t.LogChan <- log
@@ -1052,6 +1539,7 @@ func NewTask(config TaskConfig) Task {
 		Logs:       []Log{},
 		LogChan:    make(chan Log, 1e3),
 		Id:         Md5(strconv.FormatInt(time.Now().UnixMilli(), 10)+config.VideoFile, 6),
+		Thresholds: DefaultMatchThresholds,
 	}
 	return task
 }
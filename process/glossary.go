@@ -0,0 +1,192 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// GLOSSARY
+// A glossary fixes the renderings translators disagree on. Character entries set
+// the displayed name of a character, found by its original name or its id; term
+// entries replace known variant spellings in translated bodies. Characters the
+// glossary doesn't list get their character database name in its language.
+
+type GlossaryCharacter struct {
+	CharacterId int    `json:"character_id"`
+	Original    string `json:"original"`
+	Name        string `json:"name"`
+}
+
+type GlossaryTerm struct {
+	Original    string   `json:"original"`
+	Translation string   `json:"translation"`
+	Variants    []string `json:"variants"`
+}
+
+type Glossary struct {
+	Language   string              `json:"language"`
+	Characters []GlossaryCharacter `json:"characters"`
+	Terms      []GlossaryTerm      `json:"terms"`
+}
+
+type glossaryReport struct {
+	substitutions map[string]int
+	untranslated  []string
+}
+
+func ReadGlossary(file string) (result Glossary, err error) {
+	dat, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(dat, &result)
+	return
+}
+
+// usualCharacterNames is the name each character is shown with most often in the story.
+func usualCharacterNames(data StoryEventSet) map[int]string {
+	var counts = map[int]map[string]int{}
+	for _, event := range data {
+		if event.CharacterId == 0 || event.CharacterO == "" {
+			continue
+		}
+		if counts[event.CharacterId] == nil {
+			counts[event.CharacterId] = map[string]int{}
+		}
+		counts[event.CharacterId][event.CharacterO] += 1
+	}
+	var result = map[int]string{}
+	for cid, names := range counts {
+		var best string
+		for name, n := range names {
+			if n > names[best] || (n == names[best] && name < best) {
+				best = name
+			}
+		}
+		result[cid] = best
+	}
+	return result
+}
+
+// characterName matches the original name first. A character id only matches
+// while the speaker goes by the usual name, so masked names like "？？？" and
+// aliases are kept until the story reveals the speaker.
+func (g Glossary) characterName(event StoryEvent, usual map[int]string) (string, bool) {
+	for _, c := range g.Characters {
+		if c.Name != "" && c.Original != "" && c.Original == event.CharacterO {
+			return c.Name, true
+		}
+	}
+	l2d := event.character2DId()
+	if event.CharacterId == 0 ||
+		(event.CharacterO != usual[event.CharacterId] && event.CharacterO != Characters.Name(l2d, "jp")) {
+		return "", false
+	}
+	for _, c := range g.Characters {
+		if c.Name != "" && c.CharacterId == event.CharacterId {
+			return c.Name, true
+		}
+	}
+	if g.Language != "" && g.Language != "jp" {
+		if name := Characters.Name(l2d, g.Language); name != Characters.Name(l2d, "jp") {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// longestFirst orders variants so one that contains another is replaced before it.
+func longestFirst(variants []string) []string {
+	var result = append([]string{}, variants...)
+	sort.SliceStable(result, func(i, j int) bool { return len(result[i]) > len(result[j]) })
+	return result
+}
+
+// replaceVariant replaces a variant spelling with the translation, leaving text that
+// already reads as the translation alone when the variant is part of it.
+func replaceVariant(body, variant, translation string) (string, int) {
+	const placeholder = "\x00"
+	masked := body
+	if translation != "" && strings.Contains(translation, variant) {
+		masked = strings.ReplaceAll(body, translation, placeholder)
+	}
+	count := strings.Count(masked, variant)
+	if count == 0 {
+		return body, 0
+	}
+	masked = strings.ReplaceAll(masked, variant, translation)
+	return strings.ReplaceAll(masked, placeholder, translation), count
+}
+
+// Apply normalizes the names and terms of the translated story. Terms whose
+// original still appears in a translated body, or whose translation is missing
+// from a body translated from a line containing the original, are reported.
+func (g Glossary) Apply(data PJSTranslationData) (PJSTranslationData, glossaryReport) {
+	var report = glossaryReport{substitutions: map[string]int{}}
+	var result = PJSTranslationData{Data: append(StoryEventSet{}, data.Data...), Meta: data.Meta}
+	var counts = map[string]int{}
+	var usual = usualCharacterNames(data.Data)
+	for i, event := range result.Data {
+		counts[event.Type] += 1
+		if event.Type == "Period" {
+			continue
+		}
+		if name, ok := g.characterName(event, usual); ok && event.CharacterO != "" && event.Content().Character != name {
+			report.substitutions[fmt.Sprintf("%s -> %s", event.Content().Character, name)] += 1
+			result.Data[i].CharacterT = name
+		}
+		if event.ContentT == "" {
+			continue
+		}
+		body := event.ContentT
+		for _, term := range g.Terms {
+			for _, variant := range longestFirst(term.Variants) {
+				if variant == "" || variant == term.Translation {
+					continue
+				}
+				var n int
+				if body, n = replaceVariant(body, variant, term.Translation); n > 0 {
+					report.substitutions[fmt.Sprintf("%s -> %s", variant, term.Translation)] += n
+				}
+			}
+			if term.Original == "" {
+				continue
+			}
+			if strings.Contains(body, term.Original) ||
+				(strings.Contains(event.ContentO, term.Original) && term.Translation != "" && !strings.Contains(body, term.Translation)) {
+				report.untranslated = append(report.untranslated,
+					fmt.Sprintf("%s in %s No.%d", term.Original, event.Type, counts[event.Type]))
+			}
+		}
+		result.Data[i].ContentT = body
+	}
+	return result, report
+}
+
+func (t *Task) applyGlossary(data PJSTranslationData) PJSTranslationData {
+	if t.Config.Glossary == "" {
+		return data
+	}
+	glossary, err := ReadGlossary(t.Config.Glossary)
+	if err != nil {
+		go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Warning] Ignored Glossary File: %s", err.Error())})
+		return data
+	}
+	result, report := glossary.Apply(data)
+	var substitutions []string
+	for s := range report.substitutions {
+		substitutions = append(substitutions, s)
+	}
+	sort.Strings(substitutions)
+	for _, s := range substitutions {
+		go t.Log(Log{Type: "string",
+			Data: fmt.Sprintf("[Processing] Glossary Replaced %s (%d Times)", s, report.substitutions[s])})
+	}
+	for _, s := range report.untranslated {
+		go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Warning] Untranslated Term %s", s)})
+	}
+	return result
+}
@@ -0,0 +1,164 @@
+package process
+
+import (
+	"fmt"
+	"image"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// LINT
+// The linter checks a translated story before it is rendered. Findings are
+// errors when the output would be broken and warnings when it only looks wrong.
+
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+type LintFinding struct {
+	Type     string
+	Index    int
+	Severity string
+	Rule     string
+	Message  string
+}
+
+func (f LintFinding) String() string {
+	return fmt.Sprintf("%s No.%d [%s] %s: %s", f.Type, f.Index, f.Severity, f.Rule, f.Message)
+}
+
+var assTagReg = regexp.MustCompile(`\{[^{}]*}`)
+
+// isWideRune reports whether a rune takes a full em in CJK fonts.
+func isWideRune(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) ||
+		(r >= 0x3000 && r <= 0x303F) || (r >= 0xFF01 && r <= 0xFF60) || (r >= 0x2010 && r <= 0x206F)
+}
+
+// estimateTextWidth is the rendered width of a line in pixels, counting wide
+// runes as one em and the others as half an em.
+func estimateTextWidth(line string, fontSize float64) float64 {
+	var width float64
+	for _, r := range assTagReg.ReplaceAllString(line, "") {
+		if isWideRune(r) {
+			width += fontSize
+		} else {
+			width += fontSize / 2
+		}
+	}
+	return width
+}
+
+// dialogTextWidth is the wrap width and the dialog font size of a video before
+// it is matched, with the pointer template scaled to the video. The pointer
+// position cancels out of the wrap width, so the box is taken as centered.
+func dialogTextWidth(h, w int) (float64, float64) {
+	pointSize := dialogPointerSize(h, w)
+	pattern := getPatternSize(h, w)
+	pointCenter := image.Point{
+		X: (w-pattern.size[0])/2 + int(110.0*pattern.ratio),
+		Y: h - pattern.size[1] + int(42.0*pattern.ratio),
+	}
+	return dialogWrapWidth(h, w, pointCenter, pointSize), float64(dialogFontSize(pointSize))
+}
+
+func bracesBalanced(s string) bool {
+	depth := 0
+	for _, r := range s {
+		switch r {
+		case '{':
+			depth += 1
+			if depth > 1 {
+				return false
+			}
+		case '}':
+			depth -= 1
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// Lint checks every event of a story rendered on a video of the given size.
+// Line widths are measured with the font metrics, or estimated when they are nil.
+func Lint(data PJSTranslationData, h, w int, metrics *FontMetrics) []LintFinding {
+	var result []LintFinding
+	var boxWidth, fontSize = dialogTextWidth(h, w)
+	var speakers = map[string]map[string]int{}
+	for _, event := range data.Dialogs() {
+		if event.CharacterO != "" && event.CharacterT != "" {
+			if speakers[event.CharacterO] == nil {
+				speakers[event.CharacterO] = map[string]int{}
+			}
+			speakers[event.CharacterO][event.CharacterT] += 1
+		}
+	}
+	var counts = map[string]int{}
+	for _, event := range data.Data {
+		if event.Type == "Period" {
+			continue
+		}
+		counts[event.Type] += 1
+		var add = func(severity, rule, format string, a ...any) {
+			result = append(result, LintFinding{Type: event.Type, Index: counts[event.Type],
+				Severity: severity, Rule: rule, Message: fmt.Sprintf(format, a...)})
+		}
+		if event.ContentT == "" {
+			if event.ContentO != "" {
+				add(LintWarning, "empty", "Translation Is Empty")
+			}
+			continue
+		}
+		if !bracesBalanced(event.ContentT) {
+			add(LintError, "braces", "Unbalanced Braces in %q", event.ContentT)
+		}
+		if event.Type != "Dialog" {
+			continue
+		}
+		if o, t := strings.Count(event.ContentO, "\\N"), strings.Count(event.ContentT, "\\N"); o != t {
+			add(LintWarning, "line_breaks", "%d Line Breaks, Original Has %d", t, o)
+		}
+		for i, line := range strings.Split(event.ContentT, "\\N") {
+			if width := metrics.Width(line, fontSize); width > boxWidth {
+				add(LintWarning, "width", "Line %d Is %.0fpx Wide, Box Fits %.0fpx", i+1, width, boxWidth)
+			}
+		}
+		if names := speakers[event.CharacterO]; event.CharacterT != "" && len(names) > 1 {
+			usual, most := "", 0
+			for name, n := range names {
+				if n > most || (n == most && name < usual) {
+					usual, most = name, n
+				}
+			}
+			if event.CharacterT != usual {
+				add(LintWarning, "speaker", "Speaker %s Is %s in Other Lines of %s", event.CharacterT, usual, event.CharacterO)
+			}
+		}
+	}
+	return result
+}
+
+// lint checks the story before processing and logs the findings in order.
+func (t *Task) lint(data PJSTranslationData, h, w int) {
+	metrics, err := cachedFontMetrics(t.Config.FontFile, t.Config.FontIndex)
+	if err != nil {
+		t.Log(Log{Type: "string",
+			Data: fmt.Sprintf("[Warning] Estimating Text Widths, Font Unreadable: %s", err.Error())})
+	}
+	findings := Lint(data, h, w, metrics)
+	errors := 0
+	for _, f := range findings {
+		if f.Severity == LintError {
+			errors += 1
+		}
+		t.Log(Log{Type: "string", Data: fmt.Sprintf("[Warning] Lint %s", f.String())})
+	}
+	if len(findings) > 0 {
+		t.Log(Log{Type: "string",
+			Data: fmt.Sprintf("[Initial] Lint Found %d Errors, %d Warnings", errors, len(findings)-errors)})
+	}
+}
@@ -0,0 +1,54 @@
+package process
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	long := strings.Repeat("あ", 23)
+	tests := []struct {
+		name   string
+		events StoryEventSet
+		want   []string
+	}{
+		{"clean", StoryEventSet{
+			{Type: "Dialog", CharacterO: "一歌", ContentO: `おはよう\Nみんな`, ContentT: `Morning\Neveryone`},
+			{Type: "Period"},
+			{Type: "Banner", ContentO: "屋上", ContentT: "Rooftop"},
+		}, nil},
+		{"empty", StoryEventSet{
+			{Type: "Dialog", ContentO: "おはよう"},
+			{Type: "Dialog"},
+		}, []string{"Dialog No.1 [warning] empty: Translation Is Empty"}},
+		{"braces", StoryEventSet{
+			{Type: "Banner", ContentO: "屋上", ContentT: "{\\i1Rooftop"},
+			{Type: "Dialog", ContentO: "うん", ContentT: "{\\i1}{Yeah}}"},
+		}, []string{
+			"Banner No.1 [error] braces: Unbalanced Braces in \"{\\\\i1Rooftop\"",
+			"Dialog No.1 [error] braces: Unbalanced Braces in \"{\\\\i1}{Yeah}}\"",
+		}},
+		{"line breaks", StoryEventSet{
+			{Type: "Dialog", ContentO: `おはよう\Nみんな`, ContentT: "Morning everyone"},
+		}, []string{"Dialog No.1 [warning] line_breaks: 0 Line Breaks, Original Has 1"}},
+		{"width", StoryEventSet{
+			{Type: "Dialog", ContentO: `あ\Nい`, ContentT: strings.Repeat("あ", 22) + `\N` + long},
+			{Type: "Banner", ContentO: "屋上", ContentT: long},
+		}, []string{"Dialog No.1 [warning] width: Line 2 Is 1449px Wide, Box Fits 1413px"}},
+		{"speaker", StoryEventSet{
+			{Type: "Dialog", CharacterO: "一歌", CharacterT: "Ichika", ContentO: "あ", ContentT: "A"},
+			{Type: "Dialog", CharacterO: "一歌", CharacterT: "Ichika", ContentO: "い", ContentT: "B"},
+			{Type: "Dialog", CharacterO: "一歌", CharacterT: "Ichica", ContentO: "う", ContentT: "C"},
+		}, []string{"Dialog No.3 [warning] speaker: Speaker Ichica Is Ichika in Other Lines of 一歌"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, f := range Lint(PJSTranslationData{Data: tt.events}, 1080, 1920, nil) {
+			got = append(got, f.String())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: Lint = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package process
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// SHEET
+// A translation sheet is a CSV with one row per story event, readable by
+// spreadsheet programs. Line breaks are real newlines inside the cells and
+// the file starts with a UTF-8 BOM so Excel detects the encoding.
+
+var SheetColumns = []string{"index", "type", "character", "original", "translation", "notes"}
+
+const utf8BOM = "\uFEFF"
+
+// Story file kinds as told apart by ReadStoryData.
+const (
+	storyFilesNone       = ""
+	storyFilesLegacyText = "legacy_text"
+	storyFilesPJS        = "pjs"
+	storyFilesLegacy     = "legacy"
+)
+
+func storyFilesKind(files []string) string {
+	switch {
+	case len(files) > 1:
+		return storyFilesLegacyText
+	case len(files) == 1 && strings.HasSuffix(files[0], "pjs.txt"):
+		return storyFilesPJS
+	case len(files) == 1:
+		return storyFilesLegacy
+	}
+	return storyFilesNone
+}
+
+// ReadStoryData reads a PJS story file, or a legacy json file with an optional translation text file.
+func ReadStoryData(files []string) PJSTranslationData {
+	switch storyFilesKind(files) {
+	case storyFilesLegacyText:
+		return MakePJSData(files[0], files[1])
+	case storyFilesPJS:
+		return ReadPJSFile(files[0])
+	case storyFilesLegacy:
+		return MakePJSData(files[0], "")
+	}
+	return PJSTranslationData{}
+}
+
+// sheetEvents are the story events listed in a sheet, in story order; periods only close the box.
+func sheetEvents(data PJSTranslationData) []int {
+	var result []int
+	for i, event := range data.Data {
+		if event.Type != "Period" {
+			result = append(result, i)
+		}
+	}
+	return result
+}
+
+func ExportSheet(data PJSTranslationData, file string) error {
+	var buf strings.Builder
+	buf.WriteString(utf8BOM)
+	w := csv.NewWriter(&buf)
+	w.UseCRLF = true
+	if err := w.Write(SheetColumns); err != nil {
+		return err
+	}
+	for i, index := range sheetEvents(data) {
+		event := data.Data[index]
+		err := w.Write([]string{strconv.Itoa(i + 1), event.Type, event.Content().Character,
+			sheetCell(event.ContentO), sheetCell(event.ContentT), ""})
+		if err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return os.WriteFile(file, []byte(buf.String()), 0666)
+}
+
+func sheetCell(s string) string {
+	return strings.ReplaceAll(s, "\\N", "\n")
+}
+func storyText(s string) string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	return strings.ReplaceAll(s, "\n", "\\N")
+}
+
+// ImportSheet fills the translations of a sheet into the story. Rows are matched
+// by index; rows whose type differs from the story event, rows beyond the story
+// and events without a row are left untouched and listed in the report.
+func ImportSheet(data PJSTranslationData, file string) (result PJSTranslationData, report []string, err error) {
+	dat, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+	r := csv.NewReader(strings.NewReader(strings.TrimPrefix(string(dat), utf8BOM)))
+	r.FieldsPerRecord = -1
+	rows, err := r.ReadAll()
+	if err != nil {
+		return
+	}
+	if len(rows) == 0 {
+		return result, nil, errors.New("empty sheet")
+	}
+	var columns = map[string]int{}
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range SheetColumns[:5] {
+		if _, ok := columns[name]; !ok {
+			return result, nil, fmt.Errorf("sheet has no %s column", name)
+		}
+	}
+	var cell = func(row []string, name string) string {
+		if i := columns[name]; i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+
+	result = PJSTranslationData{Data: append(StoryEventSet{}, data.Data...), Meta: data.Meta}
+	events := sheetEvents(data)
+	var filled = map[int]bool{}
+	for n, row := range rows[1:] {
+		line := n + 2
+		index, e := strconv.Atoi(strings.TrimSpace(cell(row, "index")))
+		if e != nil || index < 1 || index > len(events) {
+			report = append(report, fmt.Sprintf("Row %d: Index %q Not in Story", line, cell(row, "index")))
+			continue
+		}
+		event := &result.Data[events[index-1]]
+		if rowType := strings.TrimSpace(cell(row, "type")); rowType != event.Type {
+			report = append(report, fmt.Sprintf("Row %d: Type %s Does Not Match %s No.%d", line, rowType, event.Type, index))
+			continue
+		}
+		if filled[index] {
+			report = append(report, fmt.Sprintf("Row %d: Index %d Repeated", line, index))
+			continue
+		}
+		filled[index] = true
+		// EventFromString keeps the commas of the last field, but not of the character.
+		if strings.Contains(cell(row, "character"), ",") {
+			report = append(report, fmt.Sprintf("Row %d: Commas Are Not Allowed in Character Names", line))
+			continue
+		}
+		event.ContentT = storyText(cell(row, "translation"))
+		if character := strings.TrimSpace(cell(row, "character")); character != "" && character != event.CharacterO {
+			event.CharacterT = character
+		}
+	}
+	for i := range events {
+		if !filled[i+1] {
+			report = append(report, fmt.Sprintf("Index %d: %s Has No Row", i+1, data.Data[events[i]].Type))
+		}
+	}
+	return
+}
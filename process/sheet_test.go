@@ -0,0 +1,87 @@
+package process
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSheetRoundTrip(t *testing.T) {
+	data := PJSTranslationData{Data: StoryEventSet{
+		{Type: "Dialog", CharacterId: 1, CharacterO: "一歌", ContentO: `おはよう\Nみんな`, VoiceId: "voice_01"},
+		{Type: "Period"},
+		{Type: "Dialog", CharacterId: 2, CharacterO: "咲希", CharacterT: "Saki", ContentO: "うん、行こう", ContentT: "Yeah, let's go"},
+		{Type: "Banner", ContentO: "屋上"},
+	}}
+	translated := PJSTranslationData{Data: append(StoryEventSet{}, data.Data...)}
+	translated.Data[0].CharacterT, translated.Data[0].ContentT = "Ichika", `Morning,\Neveryone`
+	translated.Data[3].ContentT = "Rooftop"
+
+	file := filepath.Join(t.TempDir(), "sheet.csv")
+	if err := ExportSheet(translated, file); err != nil {
+		t.Fatalf("ExportSheet: %v", err)
+	}
+	dat, _ := os.ReadFile(file)
+	if !strings.HasPrefix(string(dat), utf8BOM) || !strings.Contains(string(dat), "\"Morning,\r\neveryone\"") {
+		t.Errorf("ExportSheet wrote %q", dat)
+	}
+	result, report, err := ImportSheet(data, file)
+	if err != nil || len(report) != 0 {
+		t.Fatalf("ImportSheet = %q, %v", report, err)
+	}
+	if !reflect.DeepEqual(result.Data, translated.Data) {
+		t.Errorf("ImportSheet = %v, want %v", result.Data, translated.Data)
+	}
+	for _, event := range result.Data {
+		if got := EventFromString(event.String()); got != event {
+			t.Errorf("EventFromString(%q) = %v, want %v", event.String(), got, event)
+		}
+	}
+}
+
+func TestImportSheetReport(t *testing.T) {
+	data := PJSTranslationData{Data: StoryEventSet{
+		{Type: "Dialog", CharacterId: 1, CharacterO: "一歌", ContentO: "おはよう"},
+		{Type: "Dialog", CharacterId: 2, CharacterO: "咲希", ContentO: "うん"},
+		{Type: "Banner", ContentO: "屋上"},
+	}}
+	const header = "index,type,character,original,translation\n"
+	tests := []struct {
+		name, sheet string
+		report      []string
+		contentT    []string
+	}{
+		{"type mismatch", header + "1,Dialog,,,Morning\n2,Banner,,,Yeah\n3,Banner,,,Rooftop\n",
+			[]string{"Row 3: Type Banner Does Not Match Dialog No.2", "Index 2: Dialog Has No Row"},
+			[]string{"Morning", "", "Rooftop"}},
+		{"comma in character", header + "1,Dialog,\"Ichika, Saki\",,Morning\n2,Dialog,,,\"Yeah, sure\"\n3,Banner,,,Rooftop\n",
+			[]string{"Row 2: Commas Are Not Allowed in Character Names"},
+			[]string{"", "Yeah, sure", "Rooftop"}},
+		{"multiline cell", header + "1,Dialog,,,\"Morning\r\neveryone\"\n2,Dialog,,,Yeah\n3,Banner,,,Rooftop\n",
+			nil,
+			[]string{`Morning\Neveryone`, "Yeah", "Rooftop"}},
+		{"index out of story", header + "1,Dialog,,,Morning\n1,Dialog,,,Again\n4,Banner,,,Rooftop\n",
+			[]string{"Row 3: Index 1 Repeated", "Row 4: Index \"4\" Not in Story", "Index 2: Dialog Has No Row", "Index 3: Banner Has No Row"},
+			[]string{"Morning", "", ""}},
+	}
+	for _, tt := range tests {
+		file := filepath.Join(t.TempDir(), "sheet.csv")
+		if err := os.WriteFile(file, []byte(tt.sheet), 0644); err != nil {
+			t.Fatal(err)
+		}
+		result, report, err := ImportSheet(data, file)
+		if err != nil {
+			t.Fatalf("%s: ImportSheet: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(report, tt.report) {
+			t.Errorf("%s: report = %q, want %q", tt.name, report, tt.report)
+		}
+		for i, event := range result.Data {
+			if event.ContentT != tt.contentT[i] {
+				t.Errorf("%s: event %d ContentT = %q, want %q", tt.name, i+1, event.ContentT, tt.contentT[i])
+			}
+		}
+	}
+}
@@ -209,8 +209,8 @@ func (s SubtitleStyles) string() string {
 type SubtitleEventItem struct {
 	Type    string
 	Layer   int
-	Start   string
-	End     string
+	Start   Timecode
+	End     Timecode
 	Style   string
 	Name    string
 	MarginL int
@@ -222,7 +222,7 @@ type SubtitleEventItem struct {
 
 func (e SubtitleEventItem) string() string {
 	return fmt.Sprintf("%s: %d,%s,%s,%s,%s,%d,%d,%d,%s,%s\n",
-		e.Type, e.Layer, e.Start, e.End, e.Style, e.Name, e.MarginL, e.MarginR, e.MarginV, e.Effect, e.Text,
+		e.Type, e.Layer, e.Start.ASS(), e.End.ASS(), e.Style, e.Name, e.MarginL, e.MarginR, e.MarginV, e.Effect, e.Text,
 	)
 }
 
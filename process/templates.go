@@ -4,7 +4,9 @@ import (
 	"encoding/base64"
 	"fmt"
 	"image"
+	"image/png"
 	"math"
+	"strings"
 
 	"gocv.io/x/gocv"
 )
@@ -19,6 +21,12 @@ func getResizedB64Img(b64Img string, h, w int) gocv.Mat {
 func getResizedDialogPointer(h, w int) gocv.Mat {
 	return getResizedB64Img(B64Point, h, w)
 }
+
+// dialogPointerSize is the size of the dialog pointer template scaled to a video.
+func dialogPointerSize(h, w int) int {
+	config, _ := png.DecodeConfig(base64.NewDecoder(base64.StdEncoding, strings.NewReader(B64Point)))
+	return int(math.Round(float64(config.Width) * scalingRatio(h, w)))
+}
 func getResizedInterfaceMenu(h, w int) gocv.Mat {
 	return getResizedB64Img(B64Menu, h, w)
 }
@@ -33,6 +41,18 @@ func getResizedAreaEdge(h, w int) gocv.Mat {
 	gocv.Resize(resized, &resized, image.Point{X: bannerPatternSize, Y: bannerPatternSize}, 0, 0, gocv.InterpolationNearestNeighbor)
 	return resized
 }
+func getBannerEdgeTemplates(h, w int) (canny, reverse gocv.Mat, area [4]int) {
+	area = getBannerArea(h, w)
+	canny = gocv.NewMat()
+	reverse = gocv.NewMat()
+	bannerEdge := getResizedAreaEdge(h, w)
+	s := int(math.Abs(float64(area[1] - area[0])))
+	gocv.Resize(bannerEdge, &bannerEdge, image.Point{X: s, Y: s}, 0, 0, gocv.InterpolationLanczos4)
+	gocv.Canny(bannerEdge, &canny, 50, 150)
+	gocv.Threshold(bannerEdge, &reverse, 128.0, 255.0, gocv.ThresholdBinaryInv)
+	_ = bannerEdge.Close()
+	return
+}
 
 func getDialogMask(info patternSizeInfo, move [2]int) string {
 	originMask := "m 232 785 " +
@@ -93,8 +113,8 @@ func getDividerSubtitleEvent(msg string, slash int) (divider SubtitleEventItem)
 	}
 	divider.Type = "Comment"
 	divider.Layer = 1
-	divider.Start = "00:00:00.00"
-	divider.End = "00:00:00.00"
+	divider.Start = 0
+	divider.End = 0
 	divider.Style = "screen"
 	divider.Text = d + msg + d
 	return
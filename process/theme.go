@@ -0,0 +1,239 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// THEME
+// A style theme overrides the appearance fields of the generated styles, using
+// the field names of SubtitleStyleItem. Overrides are applied globally, then per
+// unit, then per style name; "size_ratio" scales the font size computed from the
+// video. Font, size and placement are not taken from a theme.
+type StyleTheme struct {
+	Global json.RawMessage            `json:"global,omitempty"`
+	Units  map[string]json.RawMessage `json:"units,omitempty"`
+	Styles map[string]json.RawMessage `json:"styles,omitempty"`
+}
+
+// styleAppearance holds the style fields a theme may override. The font, its size
+// and the margins follow the video and the task config and stay with the generator.
+type styleAppearance struct {
+	PrimaryColour   string  `json:"PrimaryColour"`
+	SecondaryColour string  `json:"SecondaryColour"`
+	OutlineColour   string  `json:"OutlineColour"`
+	BackColour      string  `json:"BackColour"`
+	Bold            int     `json:"Bold"`
+	Italic          int     `json:"Italic"`
+	Underline       int     `json:"Underline"`
+	StrikeOut       int     `json:"StrikeOut"`
+	Spacing         float64 `json:"Spacing"`
+	BorderStyle     int     `json:"BorderStyle"`
+	Outline         float64 `json:"Outline"`
+	Shadow          float64 `json:"Shadow"`
+}
+
+func newStyleAppearance(s SubtitleStyleItem) styleAppearance {
+	return styleAppearance{
+		PrimaryColour: s.PrimaryColour, SecondaryColour: s.SecondaryColour,
+		OutlineColour: s.OutlineColour, BackColour: s.BackColour,
+		Bold: s.Bold, Italic: s.Italic, Underline: s.Underline, StrikeOut: s.StrikeOut,
+		Spacing: s.Spacing, BorderStyle: s.BorderStyle, Outline: s.Outline, Shadow: s.Shadow,
+	}
+}
+func (a styleAppearance) applyTo(s SubtitleStyleItem) SubtitleStyleItem {
+	s.PrimaryColour, s.SecondaryColour = a.PrimaryColour, a.SecondaryColour
+	s.OutlineColour, s.BackColour = a.OutlineColour, a.BackColour
+	s.Bold, s.Italic, s.Underline, s.StrikeOut = a.Bold, a.Italic, a.Underline, a.StrikeOut
+	s.Spacing, s.BorderStyle, s.Outline, s.Shadow = a.Spacing, a.BorderStyle, a.Outline, a.Shadow
+	return s
+}
+
+// themeFields are the keys an override may contain.
+var themeFields = func() map[string]bool {
+	var fields = map[string]bool{"size_ratio": true}
+	var keys map[string]json.RawMessage
+	dat, _ := json.Marshal(styleAppearance{})
+	_ = json.Unmarshal(dat, &keys)
+	for key := range keys {
+		fields[key] = true
+	}
+	return fields
+}()
+
+var assColourReg = regexp.MustCompile(`^&H([0-9A-Fa-f]{2})?[0-9A-Fa-f]{6}&?$`)
+
+// ReadStyleTheme reads a JSON theme, or a YAML one when the file ends in .yaml or .yml.
+func ReadStyleTheme(file string) (theme StyleTheme, err error) {
+	dat, err := os.ReadFile(file)
+	if err != nil {
+		return
+	}
+	if ext := strings.ToLower(filepath.Ext(file)); ext == ".yaml" || ext == ".yml" {
+		var doc map[string]interface{}
+		if err = yaml.Unmarshal(dat, &doc); err != nil {
+			return
+		}
+		if dat, err = json.Marshal(doc); err != nil {
+			return
+		}
+	}
+	err = json.Unmarshal(dat, &theme)
+	return
+}
+
+// DefaultStyleTheme lists the appearance of the embedded styles as a starting point for a theme file.
+func DefaultStyleTheme() StyleTheme {
+	var theme = StyleTheme{Units: map[string]json.RawMessage{}, Styles: map[string]json.RawMessage{}}
+	styles := append(GetDialogStyle(), ChoiceStyleFormat, FullScreenTextStyleFormat)
+	for _, style := range styles {
+		theme.Styles[style.Name], _ = json.Marshal(newStyleAppearance(style))
+	}
+	units := map[string]bool{}
+	for _, entry := range Characters.Entries {
+		if entry.Unit != "" {
+			units[entry.Unit] = true
+		}
+	}
+	for unit := range units {
+		theme.Units[unit] = json.RawMessage("{}")
+	}
+	theme.Global = json.RawMessage("{}")
+	return theme
+}
+
+func applyStyleOverride(style SubtitleStyleItem, override json.RawMessage, ratio *float64) (SubtitleStyleItem, error) {
+	if len(override) == 0 {
+		return style, nil
+	}
+	var appearance = newStyleAppearance(style)
+	if err := json.Unmarshal(override, &appearance); err != nil {
+		return style, err
+	}
+	style = appearance.applyTo(style)
+	var size struct {
+		SizeRatio float64 `json:"size_ratio"`
+	}
+	if err := json.Unmarshal(override, &size); err != nil {
+		return style, err
+	}
+	if size.SizeRatio > 0 {
+		*ratio = size.SizeRatio
+	}
+	return style, nil
+}
+
+// Apply returns the styles with the theme applied, or an error naming the
+// override that could not be parsed or produced an invalid colour.
+func (th StyleTheme) Apply(styles []SubtitleStyleItem) ([]SubtitleStyleItem, error) {
+	var result []SubtitleStyleItem
+	for _, style := range styles {
+		var ratio = 1.0
+		var name = style.Name
+		var err error
+		for _, o := range []struct {
+			scope    string
+			override json.RawMessage
+		}{
+			{"global", th.Global},
+			{"unit " + Characters.StyleUnit(name), th.Units[Characters.StyleUnit(name)]},
+			{"style " + name, th.Styles[name]},
+		} {
+			style, err = applyStyleOverride(style, o.override, &ratio)
+			if err != nil {
+				return nil, fmt.Errorf("theme %s: %s", o.scope, err.Error())
+			}
+		}
+		style.Fontsize = int(float64(style.Fontsize) * ratio)
+		for field, colour := range map[string]string{
+			"PrimaryColour": style.PrimaryColour, "SecondaryColour": style.SecondaryColour,
+			"OutlineColour": style.OutlineColour, "BackColour": style.BackColour,
+		} {
+			if !assColourReg.MatchString(colour) {
+				return nil, fmt.Errorf("theme style %s: invalid %s %q", name, field, colour)
+			}
+		}
+		result = append(result, style)
+	}
+	return result, nil
+}
+
+// unknownNames lists the units and styles of the theme that match no generated style.
+func (th StyleTheme) unknownNames(styles []SubtitleStyleItem) []string {
+	var known = map[string]bool{}
+	for _, style := range styles {
+		known["style "+style.Name] = true
+		known["unit "+Characters.StyleUnit(style.Name)] = true
+	}
+	var result []string
+	for unit := range th.Units {
+		if !known["unit "+unit] {
+			result = append(result, "unit "+unit)
+		}
+	}
+	for name := range th.Styles {
+		if !known["style "+name] {
+			result = append(result, "style "+name)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// ignoredFields lists the override keys a theme cannot change, such as the font
+// size or margins, by the scope they appear in.
+func (th StyleTheme) ignoredFields() []string {
+	var scopes = map[string]json.RawMessage{"global": th.Global}
+	for unit, override := range th.Units {
+		scopes["unit "+unit] = override
+	}
+	for name, override := range th.Styles {
+		scopes["style "+name] = override
+	}
+	var result []string
+	for scope, override := range scopes {
+		var keys map[string]json.RawMessage
+		if len(override) == 0 || json.Unmarshal(override, &keys) != nil {
+			continue
+		}
+		for key := range keys {
+			if !themeFields[key] {
+				result = append(result, scope+" "+key)
+			}
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+func (t *Task) loadStyleTheme() (theme StyleTheme, err error) {
+	if t.Config.StyleTheme == "" {
+		return
+	}
+	theme, err = ReadStyleTheme(t.Config.StyleTheme)
+	if err != nil {
+		return theme, fmt.Errorf("style theme: %s", err.Error())
+	}
+	defaults := append(GetDialogStyle(), ChoiceStyleFormat, FullScreenTextStyleFormat)
+	if _, err = theme.Apply(defaults); err != nil {
+		return
+	}
+	if unknown := theme.unknownNames(defaults); len(unknown) > 0 {
+		go t.Log(Log{Type: "string",
+			Data: fmt.Sprintf("[Warning] Style Theme Has Unknown Entries: %s", strings.Join(unknown, ", "))})
+	}
+	if ignored := theme.ignoredFields(); len(ignored) > 0 {
+		go t.Log(Log{Type: "string",
+			Data: fmt.Sprintf("[Warning] Style Theme Fields Ignored, Use size_ratio for Font Size: %s",
+				strings.Join(ignored, ", "))})
+	}
+	go t.Log(Log{Type: "string", Data: "[Initial] Loaded Style Theme"})
+	return
+}
@@ -0,0 +1,77 @@
+package process
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Timecode is a subtitle time in milliseconds.
+type Timecode int
+
+func (t Timecode) parts(unit int) (h, m, s, frac int) {
+	ms := MaxInt([]int{0, int(t)})
+	n := (ms*unit + 500) / 1000
+	frac = n % unit
+	n /= unit
+	return n / 3600, n / 60 % 60, n % 60, frac
+}
+
+// ASS formats the time as H:MM:SS.cc, rounded to the nearest centisecond.
+func (t Timecode) ASS() string {
+	h, m, s, cs := t.parts(100)
+	return fmt.Sprintf("%d:%02d:%02d.%02d", h, m, s, cs)
+}
+
+// SRT formats the time as HH:MM:SS,mmm.
+func (t Timecode) SRT() string {
+	h, m, s, ms := t.parts(1000)
+	return fmt.Sprintf("%02d:%02d:%02d,%03d", h, m, s, ms)
+}
+
+// VTT formats the time as HH:MM:SS.mmm.
+func (t Timecode) VTT() string {
+	h, m, s, ms := t.parts(1000)
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", h, m, s, ms)
+}
+
+func (t Timecode) String() string {
+	return t.ASS()
+}
+
+// ParseTimecode reads ASS (H:MM:SS.cc), SRT (HH:MM:SS,mmm) and VTT (HH:MM:SS.mmm or MM:SS.mmm) times.
+func ParseTimecode(s string) (Timecode, error) {
+	s = strings.TrimSpace(strings.Replace(s, ",", ".", 1))
+	fields := strings.Split(s, ":")
+	if len(fields) < 2 || len(fields) > 3 {
+		return 0, fmt.Errorf("invalid timecode %q", s)
+	}
+	if len(fields) == 2 {
+		fields = append([]string{"0"}, fields...)
+	}
+	seconds := strings.SplitN(fields[2], ".", 2)
+	var values [3]int
+	for i, v := range []string{fields[0], fields[1], seconds[0]} {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || (i > 0 && (n > 59 || len(v) != 2)) {
+			return 0, fmt.Errorf("invalid timecode %q", s)
+		}
+		values[i] = n
+	}
+	ms := ((values[0]*60+values[1])*60 + values[2]) * 1000
+	if len(seconds) == 2 {
+		frac := seconds[1]
+		if len(frac) == 0 || len(frac) > 3 {
+			return 0, fmt.Errorf("invalid timecode %q", s)
+		}
+		n, err := strconv.Atoi(frac)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid timecode %q", s)
+		}
+		for i := len(frac); i < 3; i++ {
+			n *= 10
+		}
+		ms += n
+	}
+	return Timecode(ms), nil
+}
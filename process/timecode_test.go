@@ -0,0 +1,98 @@
+package process
+
+import "testing"
+
+func TestTimecodeFormat(t *testing.T) {
+	tests := []struct {
+		ms            Timecode
+		ass, srt, vtt string
+	}{
+		{0, "0:00:00.00", "00:00:00,000", "00:00:00.000"},
+		{-1500, "0:00:00.00", "00:00:00,000", "00:00:00.000"},
+		{4, "0:00:00.00", "00:00:00,004", "00:00:00.004"},
+		{5, "0:00:00.01", "00:00:00,005", "00:00:00.005"},
+		{994, "0:00:00.99", "00:00:00,994", "00:00:00.994"},
+		{995, "0:00:01.00", "00:00:00,995", "00:00:00.995"},
+		{1234567, "0:20:34.57", "00:20:34,567", "00:20:34.567"},
+		{3599995, "1:00:00.00", "00:59:59,995", "00:59:59.995"},
+		{3599999, "1:00:00.00", "00:59:59,999", "00:59:59.999"},
+		{36000000, "10:00:00.00", "10:00:00,000", "10:00:00.000"},
+		{45296789, "12:34:56.79", "12:34:56,789", "12:34:56.789"},
+		{360000000, "100:00:00.00", "100:00:00,000", "100:00:00.000"},
+	}
+	for _, tt := range tests {
+		if got := tt.ms.ASS(); got != tt.ass {
+			t.Errorf("Timecode(%d).ASS() = %q, want %q", tt.ms, got, tt.ass)
+		}
+		if got := tt.ms.SRT(); got != tt.srt {
+			t.Errorf("Timecode(%d).SRT() = %q, want %q", tt.ms, got, tt.srt)
+		}
+		if got := tt.ms.VTT(); got != tt.vtt {
+			t.Errorf("Timecode(%d).VTT() = %q, want %q", tt.ms, got, tt.vtt)
+		}
+		if got := tt.ms.String(); got != tt.ass {
+			t.Errorf("Timecode(%d).String() = %q, want %q", tt.ms, got, tt.ass)
+		}
+	}
+}
+
+func TestParseTimecodeRoundTrip(t *testing.T) {
+	for _, ms := range []Timecode{0, 10, 990, 1000, 59990, 1234560, 3599990, 36000000, 360000000} {
+		for _, s := range []string{ms.ASS(), ms.SRT(), ms.VTT()} {
+			got, err := ParseTimecode(s)
+			if err != nil {
+				t.Errorf("ParseTimecode(%q) error: %v", s, err)
+			} else if got != ms {
+				t.Errorf("ParseTimecode(%q) = %d, want %d", s, got, ms)
+			}
+		}
+	}
+	for _, ms := range []Timecode{1, 995, 1234567, 45296789} {
+		for _, s := range []string{ms.SRT(), ms.VTT()} {
+			if got, err := ParseTimecode(s); err != nil || got != ms {
+				t.Errorf("ParseTimecode(%q) = %d, %v, want %d", s, got, err, ms)
+			}
+		}
+	}
+}
+
+func TestParseTimecodeShortForms(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Timecode
+	}{
+		{"20:34.567", 1234567},
+		{"00:01", 1000},
+		{"0:00:01.5", 1500},
+		{" 0:00:01.25 ", 1250},
+		{"1:00:00", 3600000},
+	}
+	for _, tt := range tests {
+		if got, err := ParseTimecode(tt.s); err != nil || got != tt.want {
+			t.Errorf("ParseTimecode(%q) = %d, %v, want %d", tt.s, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseTimecodeRejectsMalformed(t *testing.T) {
+	for _, s := range []string{
+		"",
+		"12",
+		"abc",
+		"1:2:3",
+		"0:00:5.00",
+		"0:60:00.00",
+		"0:00:60.00",
+		"-1:00:00.00",
+		"1:00:00:00.00",
+		"0:0a:00.00",
+		"00:00:00.",
+		"00:00:00,0000",
+		"00:00:00.-1",
+		"00:00:00.1a",
+	} {
+		if got, err := ParseTimecode(s); err == nil {
+			t.Errorf("ParseTimecode(%q) = %d, want error", s, got)
+		}
+	}
+}
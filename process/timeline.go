@@ -0,0 +1,59 @@
+package process
+
+import "math"
+
+// timeline maps frame ids of the scanned video to subtitle times in milliseconds.
+// Frames with a recorded timestamp use it, so variable frame rate recordings keep
+// their real times; the result is optionally snapped to the frame grid of the
+// target video and shifted by the configured offset.
+type timeline struct {
+	frameTimeMs   float64
+	timestamps    map[int]float64
+	targetFrameMs float64
+	offsetMs      int
+}
+
+func (t *Task) timeline(matched matchResult) timeline {
+	tl := timeline{frameTimeMs: matched.frameTimeMs, timestamps: matched.frameTimestamps, offsetMs: t.Config.TimeOffset}
+	if t.Config.TargetFps > 0 {
+		tl.targetFrameMs = 1000.0 / t.Config.TargetFps
+	}
+	return tl
+}
+
+func (tl timeline) frameMs(frameId int) float64 {
+	if ts, ok := tl.timestamps[frameId]; ok {
+		return ts
+	}
+	if ts, ok := tl.timestamps[frameId-1]; ok {
+		return ts + tl.frameTimeMs
+	}
+	return tl.frameTimeMs * float64(frameId)
+}
+
+// At is the subtitle time of the start of a frame. It lies halfway between the
+// previous and the frame's own timestamp, so rounding it to centiseconds still
+// lands on the intended frame.
+func (tl timeline) At(frameId int) int {
+	ms := tl.frameMs(frameId)
+	prev := ms
+	if frameId > 0 {
+		prev = tl.frameMs(frameId - 1)
+	}
+	if tl.targetFrameMs > 0 {
+		k := math.Round(ms / tl.targetFrameMs)
+		ms, prev = k*tl.targetFrameMs, math.Max(0, (k-1)*tl.targetFrameMs)
+	}
+	return MaxInt([]int{0, int(math.Round((ms+prev)/2)) + tl.offsetMs})
+}
+
+// Between is the duration from frame a to frame b.
+func (tl timeline) Between(a, b int) int {
+	return int(tl.frameMs(b) - tl.frameMs(a))
+}
+func (tl timeline) Before(frameId, ms int) int {
+	return MaxInt([]int{0, tl.At(frameId) - ms})
+}
+func (tl timeline) After(frameId, ms int) int {
+	return tl.At(frameId) + ms
+}
@@ -0,0 +1,60 @@
+package process
+
+import "testing"
+
+func TestTimelineAt(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   TaskConfig
+		frameId  int
+		wantTime int
+	}{
+		{"first frame", TaskConfig{}, 0, 0},
+		{"halfway to previous frame", TaskConfig{}, 30, 983},
+		{"positive offset", TaskConfig{TimeOffset: 500}, 30, 1483},
+		{"negative offset clamps", TaskConfig{TimeOffset: -2000}, 30, 0},
+		{"target fps on grid", TaskConfig{TargetFps: 24}, 30, 979},
+		{"target fps snapped", TaskConfig{TargetFps: 24}, 31, 1021},
+		{"target fps with offset", TaskConfig{TargetFps: 24, TimeOffset: -100}, 31, 921},
+	}
+	for _, tt := range tests {
+		task := Task{Config: tt.config}
+		tl := task.timeline(matchResult{frameTimeMs: 1000.0 / 30})
+		if got := tl.At(tt.frameId); got != tt.wantTime {
+			t.Errorf("%s: At(%d) = %d, want %d", tt.name, tt.frameId, got, tt.wantTime)
+		}
+	}
+}
+
+func TestTimelineTimestamps(t *testing.T) {
+	task := Task{}
+	tl := task.timeline(matchResult{frameTimeMs: 1000.0 / 30, frameTimestamps: map[int]float64{0: 0, 1: 40, 2: 75}})
+	for frameId, want := range map[int]int{0: 0, 1: 20, 2: 58, 3: 92} {
+		if got := tl.At(frameId); got != want {
+			t.Errorf("At(%d) = %d, want %d", frameId, got, want)
+		}
+	}
+	if got := tl.Between(1, 3); got != 68 {
+		t.Errorf("Between(1, 3) = %d, want 68", got)
+	}
+}
+
+func TestTimelineRelative(t *testing.T) {
+	task := Task{Config: TaskConfig{TimeOffset: 200, TargetFps: 24}}
+	tl := task.timeline(matchResult{frameTimeMs: 1000.0 / 30})
+	if got := tl.Between(0, 30); got != 1000 {
+		t.Errorf("Between(0, 30) = %d, want 1000", got)
+	}
+	if got := tl.Between(30, 60); got != 1000 {
+		t.Errorf("Between(30, 60) = %d, want 1000", got)
+	}
+	if got := tl.Before(30, 100); got != 1079 {
+		t.Errorf("Before(30, 100) = %d, want 1079", got)
+	}
+	if got := tl.Before(0, 500); got != 0 {
+		t.Errorf("Before(0, 500) = %d, want 0", got)
+	}
+	if got := tl.After(30, 100); got != 1279 {
+		t.Errorf("After(30, 100) = %d, want 1279", got)
+	}
+}
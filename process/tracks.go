@@ -0,0 +1,69 @@
+package process
+
+import (
+	"sort"
+	"strings"
+)
+
+// TRACKS
+// Tracks are the languages published from one recording. The video is scanned
+// once and every track is generated from the shared timing with its own story
+// data, output file, font, style theme and glossary.
+
+type TrackConfig struct {
+	DataFile   []string `json:"data_file"`
+	OutputPath string   `json:"output_path"`
+	Font       string   `json:"font"`
+	FontFile   string   `json:"font_file"`
+	FontIndex  int      `json:"font_index"`
+	StyleTheme string   `json:"style_theme"`
+	Glossary   string   `json:"glossary"`
+}
+
+// trackOutputPath is "<output>.<language>.ass" next to the task output.
+func (c TaskConfig) trackOutputPath(language string) string {
+	return strings.TrimSuffix(c.OutputPath, ".ass") + "." + language + ".ass"
+}
+
+// tracks lists the task itself when no tracks are configured, or one task per
+// language sorted by language, each inheriting the fields its track leaves empty.
+func (t *Task) tracks() []*Task {
+	if len(t.Config.Tracks) == 0 {
+		return []*Task{t}
+	}
+	var languages []string
+	for language := range t.Config.Tracks {
+		languages = append(languages, language)
+	}
+	sort.Strings(languages)
+	var result []*Task
+	for _, language := range languages {
+		track := t.Config.Tracks[language]
+		config := t.Config
+		config.Language = language
+		config.Tracks = nil
+		if len(track.DataFile) > 0 {
+			config.DataFile = track.DataFile
+		}
+		config.OutputPath = track.OutputPath
+		if config.OutputPath == "" {
+			config.OutputPath = t.Config.trackOutputPath(language)
+		}
+		if track.Font != "" {
+			config.Font = track.Font
+		}
+		if track.FontFile != "" {
+			config.FontFile, config.FontIndex = track.FontFile, track.FontIndex
+		}
+		if track.StyleTheme != "" {
+			config.StyleTheme = track.StyleTheme
+		}
+		if track.Glossary != "" {
+			config.Glossary = track.Glossary
+		}
+		var task = *t
+		task.Config = config
+		result = append(result, &task)
+	}
+	return result
+}
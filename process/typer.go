@@ -0,0 +1,375 @@
+package process
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	TyperModeAlpha       = "alpha"
+	TyperModeKaraoke     = "karaoke"
+	TyperModeKaraokeFill = "karaoke_fill"
+	TyperModeNone        = "none"
+)
+
+// DefaultTyperPause is the extra wait before the unit following a pause class.
+// Keys of TaskConfig.TyperPause are class names or the punctuation itself.
+var DefaultTyperPause = map[string]int{"newline": 300}
+
+var typerPauseClasses = map[string]string{
+	"。": "period", "．": "period", "！": "period", "？": "period", "!": "period", "?": "period",
+	"、": "comma", "，": "comma", ",": "comma",
+	"…": "ellipsis", "...": "ellipsis", "—": "dash",
+}
+
+func (c TaskConfig) typerPauses() map[string]int {
+	var result = map[string]int{}
+	for k, v := range DefaultTyperPause {
+		result[k] = v
+	}
+	for k, v := range c.TyperPause {
+		result[k] = v
+	}
+	return result
+}
+
+// typerUnit is one piece of dialog text revealed at once. Tags holds the
+// override blocks written before it in the source, which are kept as they are.
+type typerUnit struct {
+	Text  string
+	Tags  string
+	Delay int
+}
+
+func (u typerUnit) text() string {
+	if u.Text == "\n" {
+		return u.Tags + "\\N"
+	}
+	return u.Tags + u.Text
+}
+func (u typerUnit) pause(pauses map[string]int) int {
+	if v, ok := pauses[u.Text]; ok {
+		return v
+	}
+	if u.Text == "\n" {
+		return pauses["newline"]
+	}
+	first, _ := utf8.DecodeRuneInString(u.Text)
+	if class, ok := typerPauseClasses[u.Text]; ok {
+		return pauses[class]
+	}
+	return pauses[typerPauseClasses[string(first)]]
+}
+
+func isGraphemeExtend(r rune) bool {
+	return unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc) ||
+		(r >= 0xFE00 && r <= 0xFE0F) || (r >= 0xE0100 && r <= 0xE01EF) ||
+		(r >= 0x1F3FB && r <= 0x1F3FF) || (r >= 0xE0020 && r <= 0xE007F) || r == 0x200D
+}
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+// splitGraphemes splits text into user-perceived characters: combining marks,
+// variation selectors, emoji modifiers and zero width joiner sequences stay with
+// their base character and regional indicators are paired into flags.
+func splitGraphemes(s string) []string {
+	var result []string
+	var runes = []rune(s)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		if isRegionalIndicator(runes[i]) && j < len(runes) && isRegionalIndicator(runes[j]) {
+			j += 1
+		}
+		for j < len(runes) && isGraphemeExtend(runes[j]) {
+			if runes[j] == 0x200D && j+1 < len(runes) {
+				j += 1
+			}
+			j += 1
+		}
+		result = append(result, string(runes[i:j]))
+		i = j
+	}
+	return result
+}
+
+// dialogTyperUnits splits a dialog body into the units revealed one at a time.
+// Line breaks become "\n", "...", "…" and "—" runs stay together, override
+// blocks are attached to the following unit and punctuation pauses delay the
+// unit after them.
+func dialogTyperUnits(body string, pauses map[string]int) []typerUnit {
+	returnChar := []string{"\\n", "\\N"}
+	bodyCopy := body
+	for _, s := range returnChar {
+		bodyCopy = strings.ReplaceAll(bodyCopy, s, "\n")
+	}
+	var units []typerUnit
+	var tags string
+	var push = func(text string) {
+		last := len(units) - 1
+		if last >= 0 && text != "\n" && tags == "" {
+			prev := units[last].Text
+			if (text == "." && (prev == "." || prev == "..")) ||
+				(text == "…" && strings.Trim(prev, "…") == "") || (text == "—" && strings.Trim(prev, "—") == "") {
+				units[last].Text += text
+				return
+			}
+		}
+		units = append(units, typerUnit{Text: text, Tags: tags})
+		tags = ""
+	}
+	for len(bodyCopy) > 0 {
+		if strings.HasPrefix(bodyCopy, "{") {
+			if end := strings.Index(bodyCopy, "}"); end > 0 {
+				tags += bodyCopy[:end+1]
+				bodyCopy = bodyCopy[end+1:]
+				continue
+			}
+		}
+		next := strings.IndexAny(bodyCopy, "{")
+		if next < 0 {
+			next = len(bodyCopy)
+		} else if next == 0 {
+			next = 1
+		}
+		for _, g := range splitGraphemes(bodyCopy[:next]) {
+			push(g)
+		}
+		bodyCopy = bodyCopy[next:]
+	}
+	if tags != "" {
+		units = append(units, typerUnit{Tags: tags})
+	}
+	for i := range units {
+		if units[i].Text == "\n" {
+			units[i].Delay += units[i].pause(pauses)
+		} else if i+1 < len(units) {
+			units[i+1].Delay += units[i].pause(pauses)
+		}
+	}
+	return units
+}
+
+func dialogBodyTyper(body string, config TaskConfig) string {
+	units := dialogTyperUnits(body, config.typerPauses())
+	fadeTime := config.TyperInterval[0]
+	charTime := config.TyperInterval[1]
+	switch {
+	case config.TyperMode == TyperModeNone || charTime <= 0:
+		res := ""
+		for _, u := range units {
+			res += u.text()
+		}
+		return res
+	case config.TyperMode == TyperModeKaraoke || config.TyperMode == TyperModeKaraokeFill:
+		return dialogBodyKaraoke(units, charTime, config.TyperMode)
+	}
+
+	res := ""
+	nextStart := 0
+	for _, u := range units {
+		if u.Text == "" {
+			res += u.Tags
+			continue
+		}
+		r := u.Tags
+		var start int
+		if fadeTime > 0 {
+			start = nextStart + u.Delay
+			end := start + fadeTime
+			r += fmt.Sprintf("{\\alphaFF\\t(%d,%d,1,\\alpha0)}", start, end)
+		}
+		if u.Text == "\n" {
+			r += "\\N"
+		} else {
+			r += u.Text
+		}
+		res += r
+		nextStart = start + charTime
+	}
+	return res
+}
+
+// dialogBodyKaraoke times the units with karaoke tags instead of one transform
+// per character. The secondary colour is made transparent, so each unit stays
+// hidden until its syllable starts (\ko) or sweeps in during it (\kf). \kf
+// only sweeps the fill, so the border and shadow of each unit are kept
+// transparent until its syllable starts.
+func dialogBodyKaraoke(units []typerUnit, charTime int, mode string) string {
+	tag := "ko"
+	if mode == TyperModeKaraokeFill {
+		tag = "kf"
+	}
+	res := "{\\2a&HFF&}"
+	nowMs := 0
+	for i, u := range units {
+		if u.Text == "" {
+			res += u.Tags
+			continue
+		}
+		duration := charTime
+		if i+1 < len(units) {
+			duration += units[i+1].Delay
+		}
+		startCs := (nowMs + 5) / 10
+		cs := (nowMs+duration+5)/10 - startCs
+		nowMs += duration
+		res += u.Tags
+		if tag == "kf" && u.Text != "\n" {
+			start := startCs * 10
+			res += fmt.Sprintf("{\\3a&HFF&\\4a&HFF&\\t(%d,%d,\\3a&H00&\\4a&H00&)}", start, start+1)
+		}
+		res += fmt.Sprintf("{\\%s%d}", tag, cs)
+		if u.Text == "\n" {
+			res += "\\N"
+		} else {
+			res += u.Text
+		}
+	}
+	return res
+}
+func dialogBodyTyperCalculator(body string, frameCount int, frameTimeMs float64, config TaskConfig) string {
+	units := dialogTyperUnits(body, config.typerPauses())
+
+	nowTime := int(frameTimeMs * float64(frameCount) * 1000.0)
+	transAlphaString := "{\\alpha&HFF&}"
+	isTransNow := false
+	charTimeNow := 0
+	fadeTime := config.TyperInterval[0]
+	charTime := config.TyperInterval[1]
+	res := ""
+	for _, u := range units {
+		if u.Text == "" {
+			res += u.Tags
+			continue
+		}
+		c := u.Text
+		addTrans := ""
+		charTimeNow += charTime + u.Delay
+		if charTimeNow < nowTime && nowTime < charTimeNow+fadeTime {
+			la := (nowTime - charTimeNow) / fadeTime * 255
+			addTrans = fmt.Sprintf("{\\alpha%d}", la)
+		} else if charTimeNow > nowTime {
+			if !isTransNow {
+				addTrans = transAlphaString
+				isTransNow = true
+			}
+		}
+		if c == "\n" {
+			c = "\\N"
+		}
+		if fadeTime > 0 && charTime > 0 {
+			res += u.Tags + addTrans + c
+		} else {
+			res += u.Tags + c
+		}
+
+	}
+	return res
+}
+
+type revealSample struct {
+	FrameId int
+	Ink     float64
+}
+
+const (
+	TyperAutoLine  = "line"
+	TyperAutoVideo = "video"
+)
+
+// measureRevealMs estimates how long the game takes to type one dialog from the
+// text ink measured over its frames: the reveal runs from 5% to 95% of the final ink.
+func measureRevealMs(frames []dialogFrame, samples map[int]float64, tl timeline) (int, bool) {
+	var peak float64
+	for _, f := range frames {
+		peak = math.Max(peak, samples[f.FrameId])
+	}
+	if peak < dialogBoundaryMinInk {
+		return 0, false
+	}
+	var start, end = -1, -1
+	for _, f := range frames {
+		ink, ok := samples[f.FrameId]
+		if !ok {
+			continue
+		}
+		if start < 0 && ink >= peak*0.05 {
+			start = f.FrameId
+		}
+		if ink >= peak*0.95 {
+			end = f.FrameId
+			break
+		}
+	}
+	if start < 0 || end <= start {
+		return 0, false
+	}
+	return tl.Between(start, end), true
+}
+
+// fitTyperCharTime spreads the measured reveal over the typed units of the body.
+func fitTyperCharTime(body string, revealMs int, pauses map[string]int) (int, bool) {
+	var count int
+	for _, u := range dialogTyperUnits(body, pauses) {
+		if u.Text != "" {
+			count += 1
+		}
+		revealMs -= u.Delay
+	}
+	if count < 5 {
+		return 0, false
+	}
+	charTime := revealMs / (count - 1)
+	if charTime < 10 || charTime > 300 {
+		return 0, false
+	}
+	return charTime, true
+}
+
+// typerCharTimes fits the typer speed of every aligned dialog according to TyperAuto.
+// Dialogs without a usable measurement keep the configured speed, and in video
+// mode every dialog uses the median of the fitted speeds.
+func (t *Task) typerCharTimes(storyData PJSTranslationData, aligned []alignedDialog, samples map[int]float64, tl timeline) []int {
+	var result = make([]int, len(aligned))
+	for i := range result {
+		result[i] = t.Config.TyperInterval[1]
+	}
+	if t.Config.TyperAuto != TyperAutoLine && t.Config.TyperAuto != TyperAutoVideo {
+		return result
+	}
+	var fitted []int
+	for i, a := range aligned {
+		if a.Line < 0 {
+			continue
+		}
+		revealMs, ok := measureRevealMs(a.Frames, samples, tl)
+		if !ok {
+			continue
+		}
+		charTime, ok := fitTyperCharTime(storyData.Dialogs()[a.Line].Content().Body, revealMs, t.Config.typerPauses())
+		if !ok {
+			continue
+		}
+		fitted = append(fitted, charTime)
+		if t.Config.TyperAuto == TyperAutoLine {
+			result[i] = charTime
+			go t.Log(Log{Type: "string",
+				Data: fmt.Sprintf("[Processing] Measured Typer Speed %dms for Dialog No.%d", charTime, i+1)})
+		}
+	}
+	if t.Config.TyperAuto == TyperAutoVideo && len(fitted) > 0 {
+		sort.Ints(fitted)
+		median := fitted[len(fitted)/2]
+		for i := range result {
+			result[i] = median
+		}
+		go t.Log(Log{Type: "string",
+			Data: fmt.Sprintf("[Processing] Measured Typer Speed %dms From %d Dialogs", median, len(fitted))})
+	}
+	return result
+}
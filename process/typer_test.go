@@ -0,0 +1,93 @@
+package process
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitGraphemes(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"", nil},
+		{"あい", []string{"あ", "い"}},
+		{"e\u0301a", []string{"e\u0301", "a"}},
+		{"👍🏻!", []string{"👍🏻", "!"}},
+		{"🇯🇵🇨", []string{"🇯🇵", "🇨"}},
+		{"👨\u200d👩\u200d👧x", []string{"👨\u200d👩\u200d👧", "x"}},
+		{"葛\U000E0100城", []string{"葛\U000E0100", "城"}},
+	}
+	for _, tt := range tests {
+		if got := splitGraphemes(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitGraphemes(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestDialogTyperUnits(t *testing.T) {
+	pauses := TaskConfig{TyperPause: map[string]int{"period": 200, "comma": 100, "ellipsis": 150}}.typerPauses()
+	tests := []struct {
+		body string
+		want []typerUnit
+	}{
+		{"あ、い。う", []typerUnit{{Text: "あ"}, {Text: "、"}, {Text: "い", Delay: 100}, {Text: "。"}, {Text: "う", Delay: 200}}},
+		{`a...b\Nc`, []typerUnit{{Text: "a"}, {Text: "..."}, {Text: "b", Delay: 150}, {Text: "\n", Delay: 300}, {Text: "c"}}},
+		{"え……——お", []typerUnit{{Text: "え"}, {Text: "……"}, {Text: "——", Delay: 150}, {Text: "お"}}},
+		{`{\i1}あ{\i0}`, []typerUnit{{Text: "あ", Tags: `{\i1}`}, {Tags: `{\i0}`}}},
+		{`..{\b1}.`, []typerUnit{{Text: ".."}, {Text: ".", Tags: `{\b1}`}}},
+		{"!?", []typerUnit{{Text: "!"}, {Text: "?", Delay: 200}}},
+	}
+	for _, tt := range tests {
+		if got := dialogTyperUnits(tt.body, pauses); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("dialogTyperUnits(%q) = %+v, want %+v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestDialogBodyTyper(t *testing.T) {
+	tests := []struct {
+		body   string
+		config TaskConfig
+		want   string
+	}{
+		{"あ、い", TaskConfig{TyperInterval: [2]int{50, 80}, TyperPause: map[string]int{"comma": 100}},
+			`{\alphaFF\t(0,50,1,\alpha0)}あ{\alphaFF\t(80,130,1,\alpha0)}、{\alphaFF\t(260,310,1,\alpha0)}い`},
+		{`あ\Nい`, TaskConfig{TyperInterval: [2]int{50, 80}},
+			`{\alphaFF\t(0,50,1,\alpha0)}あ{\alphaFF\t(380,430,1,\alpha0)}\N{\alphaFF\t(460,510,1,\alpha0)}い`},
+		{`{\i1}あ{\i0}`, TaskConfig{TyperInterval: [2]int{50, 80}},
+			`{\i1}{\alphaFF\t(0,50,1,\alpha0)}あ{\i0}`},
+		{`あ、{\i1}い\N`, TaskConfig{TyperInterval: [2]int{50, 80}, TyperMode: TyperModeNone}, `あ、{\i1}い\N`},
+		{"あい", TaskConfig{TyperInterval: [2]int{50, 0}}, "あい"},
+	}
+	for _, tt := range tests {
+		if got := dialogBodyTyper(tt.body, tt.config); got != tt.want {
+			t.Errorf("dialogBodyTyper(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestDialogBodyKaraoke(t *testing.T) {
+	config := TaskConfig{TyperInterval: [2]int{50, 80}, TyperPause: map[string]int{"period": 200}}
+	tests := []struct {
+		body, mode, want string
+	}{
+		{"あい。う", TyperModeKaraoke, `{\2a&HFF&}{\ko8}あ{\ko8}い{\ko28}。{\ko8}う`},
+		{`あ\Nい`, TyperModeKaraoke, `{\2a&HFF&}{\ko38}あ{\ko8}\N{\ko8}い`},
+		{`{\i1}あい`, TyperModeKaraoke, `{\2a&HFF&}{\i1}{\ko8}あ{\ko8}い`},
+		{"あい。う", TyperModeKaraokeFill, `{\2a&HFF&}` +
+			`{\3a&HFF&\4a&HFF&\t(0,1,\3a&H00&\4a&H00&)}{\kf8}あ` +
+			`{\3a&HFF&\4a&HFF&\t(80,81,\3a&H00&\4a&H00&)}{\kf8}い` +
+			`{\3a&HFF&\4a&HFF&\t(160,161,\3a&H00&\4a&H00&)}{\kf28}。` +
+			`{\3a&HFF&\4a&HFF&\t(440,441,\3a&H00&\4a&H00&)}{\kf8}う`},
+		{`あ\Nい`, TyperModeKaraokeFill, `{\2a&HFF&}` +
+			`{\3a&HFF&\4a&HFF&\t(0,1,\3a&H00&\4a&H00&)}{\kf38}あ{\kf8}\N` +
+			`{\3a&HFF&\4a&HFF&\t(460,461,\3a&H00&\4a&H00&)}{\kf8}い`},
+	}
+	for _, tt := range tests {
+		config.TyperMode = tt.mode
+		if got := dialogBodyTyper(tt.body, config); got != tt.want {
+			t.Errorf("dialogBodyTyper(%q, %s) = %q, want %q", tt.body, tt.mode, got, tt.want)
+		}
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"crypto/md5"
 	"encoding/hex"
-	"fmt"
 	"image"
 	"os"
 	"reflect"
@@ -145,8 +144,7 @@ func FileExist(path string) (exist bool) {
 	return
 }
 func MsToString(ms int) (res string) {
-	res = fmt.Sprintf("%02d:%02d:%02d.%02d", (ms/1000)/60/60, (ms/1000)/60%60, ms/1000%60, ms%1000/10)
-	return
+	return Timecode(ms).ASS()
 }
 func CheckMaxDistance(arr []image.Point) int {
 	var xS []int
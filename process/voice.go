@@ -0,0 +1,85 @@
+package process
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// VOICE
+// Voice lines list every detected dialog with its video time range and the
+// voice ids of its story line, so reviewers can check lines against the audio.
+
+type voiceLine struct {
+	Index     int    `json:"index"`
+	Line      int    `json:"line"`
+	Start     string `json:"start"`
+	End       string `json:"end"`
+	StartMs   int    `json:"start_ms"`
+	EndMs     int    `json:"end_ms"`
+	Character string `json:"character"`
+	VoiceId   string `json:"voice_id"`
+	Text      string `json:"text"`
+}
+
+var VoiceColumns = []string{"index", "line", "start", "end", "start_ms", "end_ms", "character", "voice_id", "text"}
+
+func makeVoiceLine(index int, line int, dialog StoryEvent, tl timeline, frames []dialogFrame) voiceLine {
+	start, end := Timecode(tl.At(frames[0].FrameId)), Timecode(tl.At(frames[len(frames)-1].FrameId+1))
+	return voiceLine{Index: index, Line: line, Start: start.SRT(), End: end.SRT(), StartMs: int(start), EndMs: int(end),
+		Character: dialog.Content().Character, VoiceId: dialog.VoiceId, Text: dialog.Content().Body}
+}
+
+// voiceComment is the ASS comment naming the voice of a line.
+func voiceComment(line voiceLine) SubtitleEventItem {
+	return SubtitleEventItem{Type: "Comment", Layer: 0, Start: Timecode(line.StartMs), End: Timecode(line.EndMs),
+		Style: "screen", Name: line.Character, Text: "voice: " + line.VoiceId}
+}
+
+// voiceExportPath is the configured export file, with the track language before
+// the extension when the task writes several tracks.
+func (t *Task) voiceExportPath() string {
+	if t.Config.Language == "" {
+		return t.Config.VoiceExport
+	}
+	ext := filepath.Ext(t.Config.VoiceExport)
+	return strings.TrimSuffix(t.Config.VoiceExport, ext) + "." + t.Config.Language + ext
+}
+
+// exportVoiceLines writes the lines as JSON when the file ends with .json, or as CSV otherwise.
+func exportVoiceLines(lines []voiceLine, file string) error {
+	if strings.EqualFold(filepath.Ext(file), ".json") {
+		dat, err := json.MarshalIndent(lines, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(file, dat, 0666)
+	}
+	var buf strings.Builder
+	buf.WriteString(utf8BOM)
+	w := csv.NewWriter(&buf)
+	w.UseCRLF = true
+	_ = w.Write(VoiceColumns)
+	for _, l := range lines {
+		_ = w.Write([]string{strconv.Itoa(l.Index), strconv.Itoa(l.Line), l.Start, l.End,
+			strconv.Itoa(l.StartMs), strconv.Itoa(l.EndMs), l.Character, l.VoiceId, sheetCell(l.Text)})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+	return os.WriteFile(file, []byte(buf.String()), 0666)
+}
+
+func (t *Task) exportVoices(lines []voiceLine) {
+	file := t.voiceExportPath()
+	if err := exportVoiceLines(lines, file); err != nil {
+		go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Warning] Voice Export Failed: %s", err.Error())})
+		return
+	}
+	go t.Log(Log{Type: "string", Data: fmt.Sprintf("[Finish] Exported %d Voice Lines to %s", len(lines), file)})
+}
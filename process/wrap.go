@@ -0,0 +1,180 @@
+package process
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"unicode/utf8"
+)
+
+// WRAP
+// Translated dialog lines are wrapped to the width of the dialog box. Breaks the
+// translator wrote are kept; new ones go between wide (CJK) characters unless
+// kinsoku forbids it, or at spaces and hyphens between other characters.
+
+const DefaultWrapMaxLines = 3
+
+const (
+	kinsokuLineStart = "、。，．・：；？！ー」』）】〉》〕］｝…‥’”,.!?:;)]}ぁぃぅぇぉっゃゅょゎァィゥェォッャュョヮヵヶ～"
+	kinsokuLineEnd   = "「『（【〈《〔［｛‘“([{"
+)
+
+type wrapWord struct {
+	text  string
+	width float64
+	space bool
+}
+
+func isWideText(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return isWideRune(r)
+}
+
+func lastRune(s string) string {
+	r, _ := utf8.DecodeLastRuneInString(s)
+	return string(r)
+}
+
+// wrapBreakable reports whether a line may break between two graphemes.
+func wrapBreakable(prev, next string) bool {
+	if prev == "" || strings.Contains(kinsokuLineStart, next) || strings.Contains(kinsokuLineEnd, lastRune(prev)) {
+		return false
+	}
+	return isWideText(prev) || isWideText(next) || strings.HasSuffix(prev, "-")
+}
+
+// taggedGrapheme is a grapheme with the override tags written before it.
+type taggedGrapheme struct {
+	tags string
+	text string
+}
+
+// splitTaggedGraphemes splits text into graphemes, keeping override tags with the
+// grapheme that follows them. Tags at the end form a last item without text.
+func splitTaggedGraphemes(s string) []taggedGrapheme {
+	var result []taggedGrapheme
+	var tags string
+	for len(s) > 0 {
+		if strings.HasPrefix(s, "{") {
+			if end := strings.Index(s, "}"); end > 0 {
+				tags += s[:end+1]
+				s = s[end+1:]
+				continue
+			}
+		}
+		next := strings.Index(s[1:], "{") + 1
+		if next == 0 {
+			next = len(s)
+		}
+		for _, g := range splitGraphemes(s[:next]) {
+			result = append(result, taggedGrapheme{tags: tags, text: g})
+			tags = ""
+		}
+		s = s[next:]
+	}
+	if tags != "" {
+		result = append(result, taggedGrapheme{tags: tags})
+	}
+	return result
+}
+
+// wrapWords splits a paragraph into runs that may not be broken, keeping
+// override tags with the text that follows them.
+func wrapWords(paragraph string, fontSize float64, metrics *FontMetrics) []wrapWord {
+	var words []wrapWord
+	var prev string
+	for _, g := range splitTaggedGraphemes(paragraph) {
+		if g.text == "" {
+			words = append(words, wrapWord{text: g.tags})
+			continue
+		}
+		space := strings.TrimSpace(g.text) == ""
+		last := len(words) - 1
+		if last >= 0 && !space && !words[last].space && !wrapBreakable(prev, g.text) {
+			words[last].text += g.tags + g.text
+			words[last].width += metrics.Width(g.text, fontSize)
+		} else {
+			words = append(words, wrapWord{text: g.tags + g.text, width: metrics.Width(g.text, fontSize), space: space})
+		}
+		prev = g.text
+	}
+	return words
+}
+
+// wrapText breaks a body into lines no wider than maxWidth. Words wider than a
+// line are broken between graphemes.
+func wrapText(body string, maxWidth, fontSize float64, metrics *FontMetrics) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(strings.ReplaceAll(body, "\\n", "\\N"), "\\N") {
+		var line string
+		var width, spaceWidth float64
+		var spaces string
+		var flush = func() {
+			lines = append(lines, line)
+			line, width, spaces, spaceWidth = "", 0, "", 0
+		}
+		for _, word := range wrapWords(paragraph, fontSize, metrics) {
+			if word.space {
+				spaces += word.text
+				spaceWidth += word.width
+				continue
+			}
+			if line != "" && width+spaceWidth+word.width > maxWidth {
+				// The spaces are dropped at the break, the tags in them are not.
+				tags := strings.Join(assTagReg.FindAllString(spaces, -1), "")
+				flush()
+				line = tags
+			} else {
+				line += spaces
+				width += spaceWidth
+			}
+			spaces, spaceWidth = "", 0
+			if word.width <= maxWidth {
+				line += word.text
+				width += word.width
+				continue
+			}
+			for _, g := range splitTaggedGraphemes(word.text) {
+				w := metrics.Width(g.text, fontSize)
+				if line != "" && width+w > maxWidth && w > 0 {
+					flush()
+				}
+				line += g.tags + g.text
+				width += w
+			}
+		}
+		flush()
+	}
+	return lines
+}
+
+// wrapDialogBody wraps a body to the box. When it needs more lines than the box
+// holds and minScale is set, the font is shrunk in steps down to minScale.
+func wrapDialogBody(body string, maxWidth, fontSize float64, metrics *FontMetrics, minScale float64) string {
+	if body == "" || maxWidth <= 0 {
+		return body
+	}
+	lines := wrapText(body, maxWidth, fontSize, metrics)
+	if minScale <= 0 || minScale >= 1 || len(lines) <= DefaultWrapMaxLines {
+		return strings.Join(lines, "\\N")
+	}
+	var scale float64
+	for step := 1; scale > minScale || step == 1; step++ {
+		scale = 1 - 0.05*float64(step)
+		if scale < minScale {
+			scale = minScale
+		}
+		lines = wrapText(body, maxWidth, fontSize*scale, metrics)
+		if len(lines) <= DefaultWrapMaxLines {
+			break
+		}
+	}
+	return fmt.Sprintf("{\\fs%d}", int(fontSize*scale)) + strings.Join(lines, "\\N")
+}
+
+// dialogWrapWidth is the width from the text margin of dialogMakeStyle to the
+// right padding of the box pattern.
+func dialogWrapWidth(h, w int, pointCenter image.Point, pointSize int) float64 {
+	_, patternInfo := getFrameData(h, w, pointCenter)
+	return float64(patternInfo.area[2]) - 110.0*patternInfo.ratio - float64(pointCenter.X-pointSize/2)
+}
@@ -0,0 +1,55 @@
+package process
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWrapText(t *testing.T) {
+	tests := []struct {
+		body     string
+		maxWidth float64
+		want     []string
+	}{
+		{"Hello world", 100, []string{"Hello world"}},
+		{"Hello world", 30, []string{"Hello", "world"}},
+		{"Hello   world", 30, []string{"Hello", "world"}},
+		{`ab\Ncd`, 100, []string{"ab", "cd"}},
+		{`ab\ncd`, 100, []string{"ab", "cd"}},
+		{"well-known fact", 30, []string{"well-", "known", "fact"}},
+		{"abcdefgh", 20, []string{"abcd", "efgh"}},
+		{"あいうえお", 30, []string{"あいう", "えお"}},
+		{"あい。うえ", 20, []string{"あ", "い。", "うえ"}},
+		{"あいうー", 30, []string{"あい", "うー"}},
+		{"あ「い」う", 30, []string{"あ", "「い」", "う"}},
+		{"あい…う", 30, []string{"あい…", "う"}},
+		{"あい……う", 30, []string{"あ", "い……", "う"}},
+		{"あいsayう", 30, []string{"あい", "sayう"}},
+		{`{\i1}Hello{\i0} world`, 30, []string{`{\i1}Hello`, `{\i0}world`}},
+		{`Hello {\i1}world`, 30, []string{"Hello", `{\i1}world`}},
+		{`{\i1}あいう{\i0}え`, 30, []string{`{\i1}あいう`, `{\i0}え`}},
+	}
+	for _, tt := range tests {
+		if got := wrapText(tt.body, tt.maxWidth, 10, nil); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("wrapText(%q, %v) = %q, want %q", tt.body, tt.maxWidth, got, tt.want)
+		}
+	}
+}
+
+func TestWrapDialogBody(t *testing.T) {
+	tests := []struct {
+		body     string
+		minScale float64
+		want     string
+	}{
+		{"", 0.8, ""},
+		{"あいうえお", 0, `あいう\Nえお`},
+		{"あいうえおかきくけこ", 0, `あいう\Nえおか\Nきくけ\Nこ`},
+		{"あいうえおかきくけこ", 0.8, `{\fs8}あいうえ\Nおかきく\Nけこ`},
+	}
+	for _, tt := range tests {
+		if got := wrapDialogBody(tt.body, 35, 10, nil, tt.minScale); got != tt.want {
+			t.Errorf("wrapDialogBody(%q, %v) = %q, want %q", tt.body, tt.minScale, got, tt.want)
+		}
+	}
+}